@@ -0,0 +1,262 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// DefaultMaxOperationCountPerBatch is max number of operations in one batch,
+// if kinetic device doesn't report MaxOperationCountPerBatch limit.
+const DefaultMaxOperationCountPerBatch = 15
+
+// DefaultMaxBatchCountPerDevice is max number of batches open at the same time,
+// if kinetic device doesn't report MaxBatchCountPerDevice limit.
+const DefaultMaxBatchCountPerDevice = 5
+
+// ErrBatchClosed is returned when operate on Batch already committed or aborted.
+var ErrBatchClosed = errors.New("Batch already committed or aborted")
+
+// ErrBatchFull is returned when Batch already has MaxOperationCountPerBatch operations.
+var ErrBatchFull = errors.New("Batch operation count exceeds MaxOperationCountPerBatch")
+
+// ErrTooManyBatches is returned when connection already has MaxBatchCountPerDevice batches open.
+var ErrTooManyBatches = errors.New("Open batch count exceeds MaxBatchCountPerDevice")
+
+var errNoBatch = errors.New("No batch started, BatchStart first")
+
+var errBatchStarted = errors.New("Batch already started, BatchEnd or BatchAbort first")
+
+// Batch is a batch operation with its own batch ID, PUT / DELETE added to Batch are
+// committed atomically by Commit, or discarded by Abort. Multiple Batch can be open on
+// one connection at the same time, up to MaxBatchCountPerDevice of the device.
+// Batch is safe for concurrent use.
+type Batch struct {
+	conn    *NonBlockConnection
+	id      uint32
+	max     int // MaxOperationCountPerBatch
+	mu      sync.Mutex
+	records []*Record
+	seqs    []int64 // Sequence of each operation
+	closed  bool
+}
+
+// BatchResult is the result of Batch commit.
+type BatchResult struct {
+	BatchStatus
+	Failed      *Record // Operation failed the batch, nil if batch committed or no operation failed
+	FailedIndex int     // Index of Failed in operations added to batch, -1 if Failed is nil
+}
+
+// openBatch takes a batch slot of connection and allocates a new batch ID for Batch.
+// ErrTooManyBatches is returned if connection already has MaxBatchCountPerDevice batches open.
+func (conn *NonBlockConnection) openBatch() (*Batch, error) {
+	select {
+	case conn.batches <- struct{}{}:
+	default:
+		return nil, ErrTooManyBatches
+	}
+
+	id := atomic.AddUint32(&conn.batchID, 1)
+
+	max := DefaultMaxOperationCountPerBatch
+	if limits := conn.service.limits(); limits != nil && limits.MaxOperationCountPerBatch > 0 {
+		max = int(limits.MaxOperationCountPerBatch)
+	}
+	return &Batch{conn: conn, id: id, max: max}, nil
+}
+
+// NewBatch starts a new Batch on connection. ErrTooManyBatches is returned if connection
+// already has MaxBatchCountPerDevice batches open.
+func (conn *NonBlockConnection) NewBatch() (*Batch, Status, error) {
+	b, err := conn.openBatch()
+	if err != nil {
+		return nil, Status{Code: ClientInternalError, ErrorMsg: err.Error()}, err
+	}
+
+	callback := &GenericCallback{}
+	f := conn.async(callback, b.start)
+	f.Wait()
+	if f.err != nil || callback.Status().Code != OK {
+		b.close()
+		return nil, callback.Status(), f.err
+	}
+
+	return b, callback.Status(), nil
+}
+
+// NewBatch starts a new Batch on connection. ErrTooManyBatches is returned if connection
+// already has MaxBatchCountPerDevice batches open.
+func (conn *BlockConnection) NewBatch() (*Batch, Status, error) {
+	return conn.nbc.NewBatch()
+}
+
+// add sends batch PUT / DELETE of entry, and records its sequence.
+func (b *Batch) add(entry *Record, put bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBatchClosed
+	}
+	if len(b.records) >= b.max {
+		return ErrBatchFull
+	}
+
+	var msg *kproto.Message
+	var cmd *kproto.Command
+	var value []byte
+	if put {
+		var err error
+		msg, cmd, err = b.conn.putCommand(entry)
+		if err != nil {
+			return err
+		}
+		value = entry.Value
+	} else {
+		msg, cmd = deleteCommand(entry)
+	}
+	cmd.Header.BatchID = &b.id
+
+	// Batch PUT / DELETE has no response, status is in response of END_BATCH
+	if err := b.conn.service.submit(msg, cmd, value, nil); err != nil {
+		return err
+	}
+	b.records = append(b.records, entry)
+	b.seqs = append(b.seqs, cmd.GetHeader().GetSequence())
+	return nil
+}
+
+// Put adds PUT of entry to batch. ErrBatchFull is returned if batch already has
+// MaxOperationCountPerBatch operations.
+func (b *Batch) Put(entry *Record) error {
+	return b.add(entry, true)
+}
+
+// Delete adds DELETE of entry to batch. ErrBatchFull is returned if batch already has
+// MaxOperationCountPerBatch operations.
+func (b *Batch) Delete(entry *Record) error {
+	return b.add(entry, false)
+}
+
+// Len returns number of operations added to batch.
+func (b *Batch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.records)
+}
+
+// start sends START_BATCH of batch, h is called with the response.
+func (b *Batch) start(h *ResponseHandler) error {
+	msg := newMessage(kproto.Message_HMACAUTH)
+	cmd := newCommand(kproto.Command_START_BATCH)
+	cmd.Header.BatchID = &b.id
+	return b.conn.service.submit(msg, cmd, nil, h)
+}
+
+// end closes batch and sends END_BATCH of batch, h is called with the response.
+// Caller must hold b.mu.
+func (b *Batch) end(h *ResponseHandler) error {
+	if err := b.close(); err != nil {
+		h.fail(Status{Code: ClientInternalError, ErrorMsg: err.Error()})
+		return err
+	}
+
+	msg := newMessage(kproto.Message_HMACAUTH)
+	cmd := newCommand(kproto.Command_END_BATCH)
+	cmd.Header.BatchID = &b.id
+	count := int32(len(b.records))
+	cmd.Body = &kproto.Command_Body{
+		Batch: &kproto.Command_Batch{
+			Count: &count,
+		},
+	}
+	return b.conn.service.submit(msg, cmd, nil, h)
+}
+
+// abort closes batch and sends ABORT_BATCH of batch, h is called with the response.
+// Caller must hold b.mu.
+func (b *Batch) abort(h *ResponseHandler) error {
+	if err := b.close(); err != nil {
+		h.fail(Status{Code: ClientInternalError, ErrorMsg: err.Error()})
+		return err
+	}
+
+	msg := newMessage(kproto.Message_HMACAUTH)
+	cmd := newCommand(kproto.Command_ABORT_BATCH)
+	cmd.Header.BatchID = &b.id
+	return b.conn.service.submit(msg, cmd, nil, h)
+}
+
+// close marks batch closed and releases its slot on connection.
+func (b *Batch) close() error {
+	if b.closed {
+		return ErrBatchClosed
+	}
+	b.closed = true
+	<-b.conn.batches
+	return nil
+}
+
+// Commit commits all operations in batch atomically. If batch fails because of an operation,
+// BatchResult.Failed is the Record of that operation.
+func (b *Batch) Commit() (*BatchResult, Status, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, Status{Code: ClientInternalError, ErrorMsg: ErrBatchClosed.Error()}, ErrBatchClosed
+	}
+
+	callback := &BatchEndCallback{}
+	f := b.conn.async(callback, b.end)
+	f.Wait()
+
+	result := &BatchResult{BatchStatus: callback.BatchStatus, FailedIndex: -1}
+	if callback.Status().Code != OK && result.FailedSequence != 0 {
+		for k, seq := range b.seqs {
+			if seq == result.FailedSequence {
+				result.Failed = b.records[k]
+				result.FailedIndex = k
+				break
+			}
+		}
+	}
+
+	return result, callback.Status(), f.err
+}
+
+// Abort discards all operations in batch.
+func (b *Batch) Abort() (Status, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return Status{Code: ClientInternalError, ErrorMsg: ErrBatchClosed.Error()}, ErrBatchClosed
+	}
+
+	callback := &GenericCallback{}
+	f := b.conn.async(callback, b.abort)
+	f.Wait()
+
+	return callback.Status(), f.err
+}
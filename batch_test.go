@@ -0,0 +1,196 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	if err = conn.BatchPut(&Record{Key: []byte("batch-a"), Sync: SyncWriteBack, Force: true}); err == nil {
+		t.Fatal("BatchPut without BatchStart expect error")
+	}
+
+	// Two batches interleaved on same connection
+	b1, status, err := conn.NewBatch()
+	if err != nil || status.Code != OK {
+		t.Fatal("NewBatch Failure", err, status.String())
+	}
+	b2, status, err := conn.NewBatch()
+	if err != nil || status.Code != OK {
+		t.Fatal("NewBatch Failure", err, status.String())
+	}
+	for _, key := range []string{"batch-a", "batch-b"} {
+		if err = b1.Put(&Record{Key: []byte(key), Value: []byte("b1"), Sync: SyncWriteBack, Force: true}); err != nil {
+			t.Fatal("Batch Put Failure", err)
+		}
+		if err = b2.Put(&Record{Key: []byte(key + "2"), Value: []byte("b2"), Sync: SyncWriteBack, Force: true}); err != nil {
+			t.Fatal("Batch Put Failure", err)
+		}
+	}
+	if _, status, err = b1.Commit(); err != nil || status.Code != OK {
+		t.Fatal("Batch Commit Failure", err, status.String())
+	}
+	if _, status, err = b2.Commit(); err != nil || status.Code != OK {
+		t.Fatal("Batch Commit Failure", err, status.String())
+	}
+	if _, _, err = b1.Commit(); err != ErrBatchClosed {
+		t.Fatal("Commit closed batch expect ErrBatchClosed", err)
+	}
+	for _, key := range []string{"batch-a", "batch-b", "batch-a2", "batch-b2"} {
+		entry, status, err := conn.Get([]byte(key))
+		if err != nil || status.Code != OK || !bytes.HasPrefix(entry.Value, []byte("b")) {
+			t.Fatal("Get batch object Failure", key, err, status.String())
+		}
+	}
+
+	// Failed operation mapped to its Record
+	b, _, err := conn.NewBatch()
+	if err != nil {
+		t.Fatal("NewBatch Failure", err)
+	}
+	missing := &Record{Key: []byte("batch-missing"), Sync: SyncWriteBack, Force: true}
+	b.Put(&Record{Key: []byte("batch-c"), Value: []byte("c"), Sync: SyncWriteBack, Force: true})
+	b.Delete(missing)
+	b.Delete(&Record{Key: []byte("batch-a"), Sync: SyncWriteBack, Force: true})
+	result, status, err := b.Commit()
+	if err != nil || status.Code != RemoteNotFound || result.Failed != missing || result.FailedIndex != 1 {
+		t.Fatal("Batch Commit with failed operation Failure", err, status.String(), result.FailedIndex)
+	}
+	if _, status, _ = conn.Get([]byte("batch-c")); status.Code != RemoteNotFound {
+		t.Fatal("Failed batch partially committed")
+	}
+
+	// Operation count and open batch count limits
+	b, _, err = conn.NewBatch()
+	if err != nil {
+		t.Fatal("NewBatch Failure", err)
+	}
+	for k := 0; k < b.max; k++ {
+		if err = b.Delete(&Record{Key: []byte("batch-a"), Sync: SyncWriteBack, Force: true}); err != nil {
+			t.Fatal("Batch Delete Failure", err)
+		}
+	}
+	if err = b.Delete(&Record{Key: []byte("batch-a"), Sync: SyncWriteBack, Force: true}); err != ErrBatchFull {
+		t.Fatal("Batch exceeds MaxOperationCountPerBatch expect ErrBatchFull", err)
+	}
+	batches := []*Batch{b}
+	for len(batches) < cap(conn.nbc.batches) {
+		b, _, err = conn.NewBatch()
+		if err != nil {
+			t.Fatal("NewBatch Failure", err)
+		}
+		batches = append(batches, b)
+	}
+	if _, _, err = conn.NewBatch(); err != ErrTooManyBatches {
+		t.Fatal("NewBatch exceeds MaxBatchCountPerDevice expect ErrTooManyBatches", err)
+	}
+	for _, b := range batches {
+		if status, err = b.Abort(); err != nil || status.Code != OK {
+			t.Fatal("Batch Abort Failure", err, status.String())
+		}
+	}
+	if _, status, _ = conn.Get([]byte("batch-a")); status.Code != OK {
+		t.Fatal("Aborted batch committed")
+	}
+
+	b, _, err = conn.NewBatch()
+	if err != nil {
+		t.Fatal("NewBatch after Abort Failure", err)
+	}
+	for _, key := range []string{"batch-a", "batch-b", "batch-a2", "batch-b2"} {
+		b.Delete(&Record{Key: []byte(key), Sync: SyncWriteBack, Force: true})
+	}
+	if _, status, err = b.Commit(); err != nil || status.Code != OK {
+		t.Fatal("Batch Commit Failure", err, status.String())
+	}
+}
+
+func TestBatchMixedAPI(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	// Batch of BatchStart keeps its ID when NewBatch opens another batch
+	if status, err := conn.BatchStart(); err != nil || status.Code != OK {
+		t.Fatal("BatchStart Failure", err, status.String())
+	}
+	if status, err := conn.BatchStart(); err == nil || status.Code == OK {
+		t.Fatal("BatchStart with batch open expect error")
+	}
+	b, status, err := conn.NewBatch()
+	if err != nil || status.Code != OK {
+		t.Fatal("NewBatch Failure", err, status.String())
+	}
+	if err = conn.BatchPut(&Record{Key: []byte("mixed-a"), Value: []byte("a"), Sync: SyncWriteBack, Force: true}); err != nil {
+		t.Fatal("BatchPut Failure", err)
+	}
+	if err = b.Put(&Record{Key: []byte("mixed-b"), Value: []byte("b"), Sync: SyncWriteBack, Force: true}); err != nil {
+		t.Fatal("Batch Put Failure", err)
+	}
+	if _, status, err = conn.BatchEnd(); err != nil || status.Code != OK {
+		t.Fatal("BatchEnd Failure", err, status.String())
+	}
+	if _, status, err = b.Commit(); err != nil || status.Code != OK {
+		t.Fatal("Batch Commit Failure", err, status.String())
+	}
+	for _, key := range []string{"mixed-a", "mixed-b"} {
+		if _, status, _ = conn.Get([]byte(key)); status.Code != OK {
+			t.Fatal("Batch not committed", key, status.String())
+		}
+	}
+
+	// BatchStart takes a batch slot
+	if status, err = conn.BatchStart(); err != nil || status.Code != OK {
+		t.Fatal("BatchStart Failure", err, status.String())
+	}
+	var batches []*Batch
+	for len(batches) < cap(conn.nbc.batches)-1 {
+		if b, _, err = conn.NewBatch(); err != nil {
+			t.Fatal("NewBatch Failure", err)
+		}
+		batches = append(batches, b)
+	}
+	if _, _, err = conn.NewBatch(); err != ErrTooManyBatches {
+		t.Fatal("NewBatch exceeds MaxBatchCountPerDevice expect ErrTooManyBatches", err)
+	}
+	if status, err = conn.BatchAbort(); err != nil || status.Code != OK {
+		t.Fatal("BatchAbort Failure", err, status.String())
+	}
+	if b, _, err = conn.NewBatch(); err != nil {
+		t.Fatal("NewBatch after BatchAbort Failure", err)
+	}
+	for _, key := range []string{"mixed-a", "mixed-b"} {
+		b.Delete(&Record{Key: []byte(key), Sync: SyncWriteBack, Force: true})
+	}
+	if _, status, err = b.Commit(); err != nil || status.Code != OK {
+		t.Fatal("Batch Commit Failure", err, status.String())
+	}
+	for _, b := range batches {
+		b.Abort()
+	}
+}
@@ -18,6 +18,8 @@
 package kinetic
 
 import (
+	"context"
+
 	kproto "github.com/Kinetic/kinetic-go/proto"
 )
 
@@ -43,36 +45,33 @@ func NewBlockConnection(op ClientOptions) (*BlockConnection, error) {
 // NoOp does nothing but wait for drive to return response.
 // On success, Status.Code will be OK
 func (conn *BlockConnection) NoOp() (Status, error) {
+	return conn.NoOpContext(context.Background())
+}
+
+// NoOpContext is NoOp with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) NoOpContext(ctx context.Context) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.NoOp(h)
+	err := conn.nbc.NoOpContext(ctx, h)
 	if err != nil {
 		return callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
 	return callback.Status(), err
 }
 
-func (conn *BlockConnection) get(key []byte, getCmd kproto.Command_MessageType) (*Record, Status, error) {
-	callback := &GetCallback{}
+func (conn *BlockConnection) get(ctx context.Context, key []byte, getCmd kproto.Command_MessageType, metaOnly bool) (*Record, Status, error) {
+	callback := &GetCallback{metaOnly: metaOnly}
 	h := NewResponseHandler(callback)
 
-	var err error
-	switch getCmd {
-	case kproto.Command_GET:
-		err = conn.nbc.Get(key, h)
-	case kproto.Command_GETPREVIOUS:
-		err = conn.nbc.GetPrevious(key, h)
-	case kproto.Command_GETNEXT:
-		err = conn.nbc.GetNext(key, h)
-	}
+	err := conn.nbc.get(ctx, key, getCmd, metaOnly, h)
 	if err != nil {
 		return nil, callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
 	return &callback.Entry, callback.Status(), err
 }
@@ -80,32 +79,86 @@ func (conn *BlockConnection) get(key []byte, getCmd kproto.Command_MessageType)
 // Get gets the object from kinetic drive with key.
 // On success, object Record will return and Status.Code = OK
 func (conn *BlockConnection) Get(key []byte) (*Record, Status, error) {
-	return conn.get(key, kproto.Command_GET)
+	return conn.get(context.Background(), key, kproto.Command_GET, false)
+}
+
+// GetContext is Get with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) GetContext(ctx context.Context, key []byte) (*Record, Status, error) {
+	return conn.get(ctx, key, kproto.Command_GET, false)
 }
 
 // GetNext gets the next object with key after the passed in key.
 // On success, object Record will return and Status.Code = OK
 func (conn *BlockConnection) GetNext(key []byte) (*Record, Status, error) {
-	return conn.get(key, kproto.Command_GETNEXT)
+	return conn.get(context.Background(), key, kproto.Command_GETNEXT, false)
+}
+
+// GetNextContext is GetNext with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) GetNextContext(ctx context.Context, key []byte) (*Record, Status, error) {
+	return conn.get(ctx, key, kproto.Command_GETNEXT, false)
 }
 
 // GetPrevious gets the previous object with key before the passed in key.
 // On success, object Record will return and Status.Code = OK
 func (conn *BlockConnection) GetPrevious(key []byte) (*Record, Status, error) {
-	return conn.get(key, kproto.Command_GETPREVIOUS)
+	return conn.get(context.Background(), key, kproto.Command_GETPREVIOUS, false)
+}
+
+// GetPreviousContext is GetPrevious with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) GetPreviousContext(ctx context.Context, key []byte) (*Record, Status, error) {
+	return conn.get(ctx, key, kproto.Command_GETPREVIOUS, false)
+}
+
+// GetMetadata gets the object metadata from kinetic drive with key, object Record
+// returned has Key, Version, Tag and Algo, without Value.
+// On success, object Record will return and Status.Code = OK
+func (conn *BlockConnection) GetMetadata(key []byte) (*Record, Status, error) {
+	return conn.get(context.Background(), key, kproto.Command_GET, true)
+}
+
+// GetMetadataContext is GetMetadata with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) GetMetadataContext(ctx context.Context, key []byte) (*Record, Status, error) {
+	return conn.get(ctx, key, kproto.Command_GET, true)
+}
+
+// GetNextMetadata gets the next object metadata with key after the passed in key, without object value.
+// On success, object Record will return and Status.Code = OK
+func (conn *BlockConnection) GetNextMetadata(key []byte) (*Record, Status, error) {
+	return conn.get(context.Background(), key, kproto.Command_GETNEXT, true)
+}
+
+// GetNextMetadataContext is GetNextMetadata with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) GetNextMetadataContext(ctx context.Context, key []byte) (*Record, Status, error) {
+	return conn.get(ctx, key, kproto.Command_GETNEXT, true)
+}
+
+// GetPreviousMetadata gets the previous object metadata with key before the passed in key, without object value.
+// On success, object Record will return and Status.Code = OK
+func (conn *BlockConnection) GetPreviousMetadata(key []byte) (*Record, Status, error) {
+	return conn.get(context.Background(), key, kproto.Command_GETPREVIOUS, true)
+}
+
+// GetPreviousMetadataContext is GetPreviousMetadata with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) GetPreviousMetadataContext(ctx context.Context, key []byte) (*Record, Status, error) {
+	return conn.get(ctx, key, kproto.Command_GETPREVIOUS, true)
 }
 
 // GetKeyRange gets list of objects' keys, which meet the criteria defined by KeyRange.
 // On success, list of objects's keys returned, and Status.Code = OK
 func (conn *BlockConnection) GetKeyRange(r *KeyRange) ([][]byte, Status, error) {
+	return conn.GetKeyRangeContext(context.Background(), r)
+}
+
+// GetKeyRangeContext is GetKeyRange with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) GetKeyRangeContext(ctx context.Context, r *KeyRange) ([][]byte, Status, error) {
 	callback := &GetKeyRangeCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.GetKeyRange(r, h)
+	err := conn.nbc.GetKeyRangeContext(ctx, r, h)
 	if err != nil {
 		return nil, callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
 	return callback.Keys, callback.Status(), err
 }
@@ -113,14 +166,19 @@ func (conn *BlockConnection) GetKeyRange(r *KeyRange) ([][]byte, Status, error)
 // GetVersion gets object DB version information.
 // On success, version information will return and Status.Code = OK
 func (conn *BlockConnection) GetVersion(key []byte) ([]byte, Status, error) {
+	return conn.GetVersionContext(context.Background(), key)
+}
+
+// GetVersionContext is GetVersion with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) GetVersionContext(ctx context.Context, key []byte) ([]byte, Status, error) {
 	callback := &GetVersionCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.GetVersion(key, h)
+	err := conn.nbc.GetVersionContext(ctx, key, h)
 	if err != nil {
 		return nil, callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
 	return callback.Version, callback.Status(), err
 }
@@ -128,46 +186,74 @@ func (conn *BlockConnection) GetVersion(key []byte) ([]byte, Status, error) {
 // Flush requests kinetic device to write all cached data to persistent media.
 // On success, Status.Code = OK
 func (conn *BlockConnection) Flush() (Status, error) {
+	return conn.FlushContext(context.Background())
+}
+
+// FlushContext is Flush with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) FlushContext(ctx context.Context) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.Flush(h)
+	err := conn.nbc.FlushContext(ctx, h)
 	if err != nil {
 		return callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
 	return callback.Status(), err
 }
 
 // Delete deletes object from kinetic device.
+// If entry.Force is false, object is only deleted when its version on device equals entry.Version.
 // On success, Status.Code = OK
+// On version mismatch, Status.Code = RemoteVersionMismatch and error is *VersionMismatchError.
 func (conn *BlockConnection) Delete(entry *Record) (Status, error) {
+	return conn.DeleteContext(context.Background(), entry)
+}
+
+// DeleteContext is Delete with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) DeleteContext(ctx context.Context, entry *Record) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.Delete(entry, h)
+	err := conn.nbc.DeleteContext(ctx, entry, h)
 	if err != nil {
 		return callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
-	return callback.Status(), err
+	return callback.Status(), versionMismatchError(entry, callback.Status(), err)
 }
 
 // Put store object to kinetic device.
+// If entry.Force is false, object is only stored when its version on device equals entry.Version,
+// entry.NewVersion will be the object version after PUT.
 // On success, Status.Code = OK
+// On version mismatch, Status.Code = RemoteVersionMismatch and error is *VersionMismatchError.
 func (conn *BlockConnection) Put(entry *Record) (Status, error) {
+	return conn.PutContext(context.Background(), entry)
+}
+
+// PutContext is Put with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) PutContext(ctx context.Context, entry *Record) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.Put(entry, h)
+	err := conn.nbc.PutContext(ctx, entry, h)
 	if err != nil {
 		return callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
-	return callback.Status(), err
+	return callback.Status(), versionMismatchError(entry, callback.Status(), err)
+}
+
+// versionMismatchError converts RemoteVersionMismatch status to VersionMismatchError.
+func versionMismatchError(entry *Record, status Status, err error) error {
+	if err == nil && status.Code == RemoteVersionMismatch {
+		return &VersionMismatchError{Key: entry.Key, Version: entry.Version, Status: status}
+	}
+	return err
 }
 
 // P2PPush performs peer to peer push operation
@@ -186,6 +272,7 @@ func (conn *BlockConnection) P2PPush(request *P2PPushRequest) (*P2PPushStatus, S
 
 // BatchStart starts new batch operation, all following batch PUT / DELETE share same batch ID until
 // BatchEnd or BatchAbort is called.
+// Only one such batch can be open on connection at a time, use NewBatch for concurrent batches.
 func (conn *BlockConnection) BatchStart() (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
@@ -243,14 +330,19 @@ func (conn *BlockConnection) BatchAbort() (Status, error) {
 // GetLog gets kinetic device Log information. Can request single LogType or multiple LogType.
 // On success, device Log information will return, and Status.Code = OK
 func (conn *BlockConnection) GetLog(logs []LogType) (*Log, Status, error) {
+	return conn.GetLogContext(context.Background(), logs)
+}
+
+// GetLogContext is GetLog with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) GetLogContext(ctx context.Context, logs []LogType) (*Log, Status, error) {
 	callback := &GetLogCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.GetLog(logs, h)
+	err := conn.nbc.GetLogContext(ctx, logs, h)
 	if err != nil {
 		return nil, callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
 	return &callback.Logs, callback.Status(), err
 }
@@ -395,14 +487,19 @@ func (conn *BlockConnection) SetACL(acls []ACL) (Status, error) {
 // if the end to end integrity is known to the device, if the
 // end to end integrity field is correct.
 func (conn *BlockConnection) MediaScan(op *MediaOperation, pri Priority) (Status, error) {
+	return conn.MediaScanContext(context.Background(), op, pri)
+}
+
+// MediaScanContext is MediaScan with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) MediaScanContext(ctx context.Context, op *MediaOperation, pri Priority) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.MediaScan(op, pri, h)
+	err := conn.nbc.MediaScanContext(ctx, op, pri, h)
 	if err != nil {
 		return callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
 	return callback.Status(), err
 }
@@ -411,14 +508,19 @@ func (conn *BlockConnection) MediaScan(op *MediaOperation, pri Priority) (Status
 // defragmentation, compaction, garbage collection, compression
 // could be things accomplished using the media optimize command.
 func (conn *BlockConnection) MediaOptimize(op *MediaOperation, pri Priority) (Status, error) {
+	return conn.MediaOptimizeContext(context.Background(), op, pri)
+}
+
+// MediaOptimizeContext is MediaOptimize with ctx to cancel the operation and set its deadline.
+func (conn *BlockConnection) MediaOptimizeContext(ctx context.Context, op *MediaOperation, pri Priority) (Status, error) {
 	callback := &GenericCallback{}
 	h := NewResponseHandler(callback)
-	err := conn.nbc.MediaOptimize(op, pri, h)
+	err := conn.nbc.MediaOptimizeContext(ctx, op, pri, h)
 	if err != nil {
 		return callback.Status(), err
 	}
 
-	err = conn.nbc.Listen(h)
+	err = conn.nbc.ListenContext(ctx, h)
 
 	return callback.Status(), err
 }
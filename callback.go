@@ -56,7 +56,8 @@ func (c *GenericCallback) Status() Status {
 // GetCallback is the Callback for Command_GET Message
 type GetCallback struct {
 	GenericCallback
-	Entry Record // Entity information
+	Entry    Record // Entity information
+	metaOnly bool   // Request is GET metadata only
 }
 
 // Success function extracts object information from response message and
@@ -67,6 +68,7 @@ func (c *GetCallback) Success(resp *kproto.Command, value []byte) {
 	c.Entry.Tag = resp.GetBody().GetKeyValue().GetTag()
 	c.Entry.Version = resp.GetBody().GetKeyValue().GetDbVersion()
 	c.Entry.Algo = convertAlgoFromProto(resp.GetBody().GetKeyValue().GetAlgorithm())
+	c.Entry.MetaOnly = c.metaOnly
 
 	c.Entry.Value = value
 }
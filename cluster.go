@@ -0,0 +1,506 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultVirtualNodes is default number of virtual nodes on hash ring for each drive in Cluster.
+const DefaultVirtualNodes = 128
+
+// ClusterOptions specify drives in Cluster and how keys are placed.
+type ClusterOptions struct {
+	Drives       []ClientOptions // Kinetic devices in cluster
+	VirtualNodes int             // Virtual nodes on hash ring for each drive, 0 to use DefaultVirtualNodes
+}
+
+// Cluster routes Get / Put / Delete to the kinetic device owning the key. Keys are placed by
+// consistent hashing with virtual nodes, so adding or removing a drive only moves keys owned
+// by that drive.
+type Cluster struct {
+	mu      sync.RWMutex
+	vnodes  int
+	ring    hashRing
+	drives  map[string]*BlockConnection // Drives on hash ring, by drive name
+	leaving map[string]*BlockConnection // Drives removed from hash ring, waiting for Rebalance
+}
+
+// RangeMove is a range of hash ring which changed owner drive. Keys hashed into (Start, End]
+// move from drive From to drive To. If Start >= End, the range wraps around the ring.
+type RangeMove struct {
+	Start uint64
+	End   uint64
+	From  string
+	To    string
+}
+
+// MovePlan lists ranges of hash ring changed owner drive after drive added or removed.
+type MovePlan struct {
+	Moves []RangeMove
+}
+
+// Move returns the RangeMove for key, nil if key owner doesn't change.
+func (p *MovePlan) Move(key []byte) *RangeMove {
+	h := hashKey(key)
+	for k := range p.Moves {
+		m := &p.Moves[k]
+		if m.Start < m.End && h > m.Start && h <= m.End {
+			return m
+		}
+		if m.Start >= m.End && (h > m.Start || h <= m.End) {
+			return m
+		}
+	}
+	return nil
+}
+
+type vnode struct {
+	hash  uint64
+	drive string
+}
+
+// hashRing is list of virtual nodes sorted by hash.
+type hashRing []vnode
+
+func (r hashRing) Len() int           { return len(r) }
+func (r hashRing) Less(i, j int) bool { return r[i].hash < r[j].hash }
+func (r hashRing) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// owner returns the drive owning hash h, which is the first virtual node at or after h.
+func (r hashRing) owner(h uint64) string {
+	if len(r) == 0 {
+		return ""
+	}
+	k := sort.Search(len(r), func(i int) bool { return r[i].hash >= h })
+	if k == len(r) {
+		k = 0
+	}
+	return r[k].drive
+}
+
+// owners returns up to n distinct drives for hash h, walking the ring from the owner of h.
+func (r hashRing) owners(h uint64, n int) []string {
+	drives := make([]string, 0, n)
+	if len(r) == 0 {
+		return drives
+	}
+	k := sort.Search(len(r), func(i int) bool { return r[i].hash >= h })
+	for i := 0; i < len(r) && len(drives) < n; i++ {
+		d := r[(k+i)%len(r)].drive
+		dup := false
+		for _, o := range drives {
+			if o == d {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			drives = append(drives, d)
+		}
+	}
+	return drives
+}
+
+// with returns new hash ring with virtual nodes of drive added.
+func (r hashRing) with(drive string, vnodes int) hashRing {
+	n := make(hashRing, len(r), len(r)+vnodes)
+	copy(n, r)
+	for k := 0; k < vnodes; k++ {
+		n = append(n, vnode{hash: hashKey([]byte(drive + "#" + strconv.Itoa(k))), drive: drive})
+	}
+	sort.Sort(n)
+	return n
+}
+
+// without returns new hash ring with virtual nodes of drive removed.
+func (r hashRing) without(drive string) hashRing {
+	n := make(hashRing, 0, len(r))
+	for _, v := range r {
+		if v.drive != drive {
+			n = append(n, v)
+		}
+	}
+	return n
+}
+
+func hashKey(key []byte) uint64 {
+	sum := sha1.Sum(key)
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// planMove computes ranges of hash ring changed owner from ring old to ring new.
+func planMove(old, new hashRing) *MovePlan {
+	points := make([]uint64, 0, len(old)+len(new))
+	for _, v := range old {
+		points = append(points, v.hash)
+	}
+	for _, v := range new {
+		points = append(points, v.hash)
+	}
+	sort.Sort(uint64s(points))
+	uniq := points[:0]
+	for k, p := range points {
+		if k == 0 || p != points[k-1] {
+			uniq = append(uniq, p)
+		}
+	}
+	points = uniq
+
+	plan := &MovePlan{}
+	if len(points) == 0 {
+		return plan
+	}
+	// Range (points[k-1], points[k]] is owned by the same drive in each ring.
+	// First range wraps around the ring from the last point.
+	prev := points[len(points)-1]
+	for _, p := range points {
+		from, to := old.owner(p), new.owner(p)
+		if from != to {
+			n := len(plan.Moves)
+			if n > 0 && plan.Moves[n-1].End == prev && plan.Moves[n-1].From == from && plan.Moves[n-1].To == to {
+				plan.Moves[n-1].End = p
+			} else {
+				plan.Moves = append(plan.Moves, RangeMove{Start: prev, End: p, From: from, To: to})
+			}
+		}
+		prev = p
+	}
+	return plan
+}
+
+type uint64s []uint64
+
+func (s uint64s) Len() int           { return len(s) }
+func (s uint64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// DriveName returns the name of drive in Cluster, which is "host:port".
+func DriveName(op ClientOptions) string {
+	return net.JoinHostPort(op.Host, strconv.Itoa(op.Port))
+}
+
+// NewCluster is helper function to connect to all drives in cluster.
+func NewCluster(op ClusterOptions) (*Cluster, error) {
+	vnodes := op.VirtualNodes
+	if vnodes <= 0 {
+		vnodes = DefaultVirtualNodes
+	}
+	c := &Cluster{
+		vnodes:  vnodes,
+		drives:  make(map[string]*BlockConnection),
+		leaving: make(map[string]*BlockConnection),
+	}
+
+	for _, d := range op.Drives {
+		if _, err := c.AddDrive(d); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Drive returns the name of drive owning key.
+func (c *Cluster) Drive(key []byte) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ring.owner(hashKey(key))
+}
+
+// Drives returns names of all drives on hash ring.
+func (c *Cluster) Drives() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.drives))
+	for name := range c.drives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// conn returns connection to the drive owning key.
+func (c *Cluster) conn(key []byte) (*BlockConnection, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	conn, ok := c.drives[c.ring.owner(hashKey(key))]
+	if !ok {
+		return nil, errors.New("No drive in cluster")
+	}
+	return conn, nil
+}
+
+// replicas returns connections to up to n distinct drives for key, the first one is the owner.
+func (c *Cluster) replicas(key []byte, n int) ([]string, []*BlockConnection) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := c.ring.owners(hashKey(key), n)
+	conns := make([]*BlockConnection, len(names))
+	for k, name := range names {
+		conns[k] = c.drives[name]
+	}
+	return names, conns
+}
+
+// AddDrive connects to drive and adds it to hash ring. Keys in returned MovePlan are still on their
+// previous drive, Rebalance is required to move them to the new drive.
+func (c *Cluster) AddDrive(op ClientOptions) (*MovePlan, error) {
+	name := DriveName(op)
+	c.mu.RLock()
+	_, ok := c.drives[name]
+	c.mu.RUnlock()
+	if ok {
+		return nil, errors.New("Drive already in cluster: " + name)
+	}
+
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		klog.Error("Can't connect to drive ", name)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.drives[name]; ok {
+		conn.Close()
+		return nil, errors.New("Drive already in cluster: " + name)
+	}
+	ring := c.ring.with(name, c.vnodes)
+	plan := planMove(c.ring, ring)
+	c.ring = ring
+	c.drives[name] = conn
+
+	return plan, nil
+}
+
+// RemoveDrive removes drive from hash ring. Connection to the drive is kept until Rebalance
+// moves keys in returned MovePlan to their new drives.
+func (c *Cluster) RemoveDrive(name string) (*MovePlan, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn, ok := c.drives[name]
+	if !ok {
+		return nil, errors.New("Drive not in cluster: " + name)
+	}
+	ring := c.ring.without(name)
+	plan := planMove(c.ring, ring)
+	c.ring = ring
+	delete(c.drives, name)
+	c.leaving[name] = conn
+
+	return plan, nil
+}
+
+// Rebalance moves keys in plan from their previous drive to new owner drive.
+// Connections to drives removed by RemoveDrive are closed after their keys moved.
+func (c *Cluster) Rebalance(plan *MovePlan) error {
+	sources := make(map[string]bool)
+	for _, m := range plan.Moves {
+		sources[m.From] = true
+	}
+
+	for from := range sources {
+		c.mu.RLock()
+		src, ok := c.drives[from]
+		if !ok {
+			src, ok = c.leaving[from]
+		}
+		c.mu.RUnlock()
+		if !ok {
+			return errors.New("Drive not in cluster: " + from)
+		}
+
+		if err := c.moveKeys(src, plan); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		if conn, ok := c.leaving[from]; ok {
+			conn.Close()
+			delete(c.leaving, from)
+		}
+		c.mu.Unlock()
+	}
+
+	return nil
+}
+
+// moveKeys moves keys on drive src which are in plan to their new owner drive.
+func (c *Cluster) moveKeys(src *BlockConnection, plan *MovePlan) error {
+	r := fullKeyRange(src)
+	for {
+		keys, status, err := src.GetKeyRange(r)
+		if err != nil {
+			return err
+		}
+		if status.Code != OK {
+			return status
+		}
+
+		for _, key := range keys {
+			m := plan.Move(key)
+			if m == nil {
+				continue
+			}
+			c.mu.RLock()
+			dst, ok := c.drives[m.To]
+			c.mu.RUnlock()
+			if !ok {
+				return errors.New("Drive not in cluster: " + m.To)
+			}
+			if err = moveKey(src, dst, key); err != nil {
+				return err
+			}
+		}
+
+		if len(keys) == 0 {
+			return nil
+		}
+		r.StartKey = keys[len(keys)-1]
+		r.StartKeyInclusive = false
+	}
+}
+
+// fullKeyRange returns KeyRange covering all keys on drive, with Max set to device limit.
+func fullKeyRange(conn *BlockConnection) *KeyRange {
+	r := prefixRange(nil, conn.nbc.service.maxKeySize())
+	r.Max = conn.nbc.service.maxKeyRangeCount()
+	return r
+}
+
+// moveKey copies object with key from drive src to drive dst, then deletes it from src.
+// Object version is kept. Copy is create only, so data already on dst is never overwritten;
+// if dst has the same or a newer version, the copy on src is just deleted, an older version
+// on dst is a conflict. Object is deleted from src only if not changed since read.
+func moveKey(src, dst *BlockConnection, key []byte) error {
+	record, status, err := src.Get(key)
+	if err != nil {
+		return err
+	}
+	if status.Code == RemoteNotFound {
+		// Deleted after listed
+		return nil
+	}
+	if status.Code != OK {
+		return status
+	}
+
+	version := record.Version
+	record.Version = nil
+	record.NewVersion = version
+	record.Force = false
+	record.Sync = SyncWriteThrough
+	status, err = dst.Put(record)
+	if IsVersionMismatch(err) {
+		// Key already on dst, old copy on src can go only if dst is not behind it
+		dstVersion, status, err := dst.GetVersion(key)
+		if err != nil {
+			return err
+		}
+		if status.Code != OK {
+			return status
+		}
+		if bytes.Compare(dstVersion, version) < 0 {
+			return fmt.Errorf("Version conflict moving key %q, version %x on source, %x on destination",
+				key, version, dstVersion)
+		}
+		status, err = src.Delete(&Record{Key: key, Version: version, Sync: SyncWriteThrough})
+		if err != nil {
+			return err
+		}
+		if status.Code != OK && status.Code != RemoteNotFound {
+			return status
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if status.Code != OK {
+		return status
+	}
+
+	status, err = src.Delete(&Record{Key: key, Version: version, Sync: SyncWriteThrough})
+	if IsVersionMismatch(err) {
+		// Changed on src after read, keep the newer object on src and drop the copy
+		_, err = dst.Delete(&Record{Key: key, Version: version, Sync: SyncWriteThrough})
+		if IsVersionMismatch(err) {
+			return nil
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if status.Code != OK && status.Code != RemoteNotFound {
+		return status
+	}
+	return nil
+}
+
+// Get gets the object with key from the drive owning key.
+func (c *Cluster) Get(key []byte) (*Record, Status, error) {
+	conn, err := c.conn(key)
+	if err != nil {
+		return nil, Status{Code: ClientInternalError, ErrorMsg: err.Error()}, err
+	}
+	return conn.Get(key)
+}
+
+// Put stores object to the drive owning entry.Key.
+func (c *Cluster) Put(entry *Record) (Status, error) {
+	conn, err := c.conn(entry.Key)
+	if err != nil {
+		return Status{Code: ClientInternalError, ErrorMsg: err.Error()}, err
+	}
+	return conn.Put(entry)
+}
+
+// Delete deletes object from the drive owning entry.Key.
+func (c *Cluster) Delete(entry *Record) (Status, error) {
+	conn, err := c.conn(entry.Key)
+	if err != nil {
+		return Status{Code: ClientInternalError, ErrorMsg: err.Error()}, err
+	}
+	return conn.Delete(entry)
+}
+
+// Close closes connections to all drives in cluster.
+func (c *Cluster) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, conn := range c.drives {
+		conn.Close()
+		delete(c.drives, name)
+	}
+	for name, conn := range c.leaving {
+		conn.Close()
+		delete(c.leaving, name)
+	}
+	c.ring = nil
+}
@@ -0,0 +1,179 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Kinetic/kinetic-go/simulator"
+)
+
+func startSimulators(t *testing.T, n int) ([]*simulator.Simulator, []ClientOptions) {
+	sims := make([]*simulator.Simulator, n)
+	ops := make([]ClientOptions, n)
+	for k := 0; k < n; k++ {
+		sim, err := simulator.New(simulator.Options{Host: option.Host})
+		if err != nil {
+			t.Fatal("Simulator start failure", err)
+		}
+		sims[k] = sim
+		ops[k] = option
+		ops[k].Port = sim.Port()
+	}
+	return sims, ops
+}
+
+func checkClusterKeys(t *testing.T, c *Cluster, count int) {
+	for k := 0; k < count; k++ {
+		key := []byte(fmt.Sprintf("cluster%04d", k))
+		record, status, err := c.Get(key)
+		if err != nil || status.Code != OK || !bytes.Equal(record.Value, key) {
+			t.Fatal("Cluster Get Failure", string(key), err, status.String())
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	sims, ops := startSimulators(t, 4)
+	for _, sim := range sims {
+		defer sim.Close()
+	}
+
+	c, err := NewCluster(ClusterOptions{Drives: ops[:3]})
+	if err != nil {
+		t.Fatal("Cluster create Failure", err)
+	}
+	defer c.Close()
+
+	const count = 500
+	for k := 0; k < count; k++ {
+		key := []byte(fmt.Sprintf("cluster%04d", k))
+		status, err := c.Put(&Record{Key: key, Value: key, Version: []byte("v1"), NewVersion: []byte("v1"), Force: true, Sync: SyncWriteBack})
+		if err != nil || status.Code != OK {
+			t.Fatal("Cluster Put Failure", err, status.String())
+		}
+	}
+	checkClusterKeys(t, c, count)
+
+	// Add drive, only keys owned by new drive move
+	plan, err := c.AddDrive(ops[3])
+	if err != nil {
+		t.Fatal("Cluster AddDrive Failure", err)
+	}
+	moved := 0
+	var newer []byte
+	for k := 0; k < count; k++ {
+		key := []byte(fmt.Sprintf("cluster%04d", k))
+		m := plan.Move(key)
+		if m != nil {
+			moved++
+			if newer == nil && k > 0 {
+				newer = key
+			}
+			if m.To != DriveName(ops[3]) || c.Drive(key) != m.To {
+				t.Fatal("Key moves to wrong drive", string(key), m.To)
+			}
+		}
+	}
+	if moved == 0 || moved > count/2 {
+		t.Fatal("Unexpected number of keys to move", moved)
+	}
+	from := c.drives[plan.Move(newer).From]
+	// Newer object written to new owner before Rebalance is not overwritten
+	status, err := c.Put(&Record{Key: newer, Value: newer, Version: []byte("v1"), NewVersion: []byte("v2"), Force: true, Sync: SyncWriteBack})
+	if err != nil || status.Code != OK {
+		t.Fatal("Cluster Put Failure", err, status.String())
+	}
+	if err = c.Rebalance(plan); err != nil {
+		t.Fatal("Cluster Rebalance Failure", err)
+	}
+	checkClusterKeys(t, c, count)
+	record, _, _ := c.Get(newer)
+	if !bytes.Equal(record.Version, []byte("v2")) {
+		t.Fatal("Newer object overwritten by Rebalance", string(newer), string(record.Version))
+	}
+	if _, status, _ = from.Get(newer); status.Code != RemoteNotFound {
+		t.Fatal("Old copy of newer object not deleted by Rebalance", string(newer), status.String())
+	}
+
+	// Version kept after move
+	record, _, _ = c.Get([]byte("cluster0000"))
+	if !bytes.Equal(record.Version, []byte("v1")) {
+		t.Fatal("Object version changed after Rebalance", string(record.Version))
+	}
+
+	// Remove drive, its keys move to others
+	name := DriveName(ops[0])
+	plan, err = c.RemoveDrive(name)
+	if err != nil {
+		t.Fatal("Cluster RemoveDrive Failure", err)
+	}
+	for _, m := range plan.Moves {
+		if m.From != name {
+			t.Fatal("Key moves from drive not removed", m.From)
+		}
+	}
+	if err = c.Rebalance(plan); err != nil {
+		t.Fatal("Cluster Rebalance Failure", err)
+	}
+	checkClusterKeys(t, c, count)
+	if len(c.Drives()) != 3 {
+		t.Fatal("Unexpected drives in cluster", c.Drives())
+	}
+}
+
+func TestMoveKeyConflict(t *testing.T) {
+	sims, ops := startSimulators(t, 2)
+	for _, sim := range sims {
+		defer sim.Close()
+	}
+	src, err := NewBlockConnection(ops[0])
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer src.Close()
+	dst, err := NewBlockConnection(ops[1])
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer dst.Close()
+
+	key := []byte("conflict")
+	for _, p := range []struct {
+		conn    *BlockConnection
+		version string
+	}{{src, "v2"}, {dst, "v1"}} {
+		status, err := p.conn.Put(&Record{Key: key, Value: key, NewVersion: []byte(p.version), Force: true, Sync: SyncWriteBack})
+		if err != nil || status.Code != OK {
+			t.Fatal("Blocking Put Failure", err, status.String())
+		}
+	}
+
+	// Older version on dst is a conflict, both copies are kept
+	if err = moveKey(src, dst, key); err == nil {
+		t.Fatal("moveKey expected failure for older version on destination")
+	}
+	if _, status, _ := src.Get(key); status.Code != OK {
+		t.Fatal("Object deleted from source on conflict", status.String())
+	}
+	if record, _, _ := dst.Get(key); !bytes.Equal(record.Version, []byte("v1")) {
+		t.Fatal("Object on destination overwritten on conflict", string(record.Version))
+	}
+}
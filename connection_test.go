@@ -19,8 +19,14 @@ package kinetic
 
 import (
 	"bytes"
+	"context"
+	"net"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/Kinetic/kinetic-go/simulator"
 )
 
 var (
@@ -39,12 +45,20 @@ var option = ClientOptions{
 
 func TestMain(m *testing.M) {
 	SetLogLevel(LogLevelDebug)
+	sim, err := simulator.New(simulator.Options{Host: option.Host})
+	if err != nil {
+		os.Exit(-1)
+	}
+	option.Port = sim.Port()
+
 	blockConn, _ = NewBlockConnection(option)
 	if blockConn != nil {
 		code := m.Run()
 		blockConn.Close()
+		sim.Close()
 		os.Exit(code)
 	} else {
+		sim.Close()
 		os.Exit(-1)
 	}
 }
@@ -181,6 +195,434 @@ func TestBlockDelete(t *testing.T) {
 	}
 }
 
+func TestBlockPutDeleteVersion(t *testing.T) {
+	entry := Record{
+		Key:        []byte("object-version"),
+		Value:      []byte("ABCDEFG"),
+		NewVersion: []byte("v1"),
+		Sync:       SyncWriteThrough,
+		Algo:       AlgorithmSHA1,
+		Force:      true,
+	}
+	status, err := blockConn.Put(&entry)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+
+	// PUT with wrong expected version should fail
+	entry.Force = false
+	entry.Version = []byte("v0")
+	entry.NewVersion = []byte("v2")
+	status, err = blockConn.Put(&entry)
+	if _, ok := err.(*VersionMismatchError); !ok || status.Code != RemoteVersionMismatch {
+		t.Fatal("Blocking Put expected VersionMismatchError", err, status.String())
+	}
+
+	// PUT with correct expected version
+	entry.Version = []byte("v1")
+	status, err = blockConn.Put(&entry)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+
+	version, status, err := blockConn.GetVersion(entry.Key)
+	if err != nil || status.Code != OK || !bytes.Equal(version, []byte("v2")) {
+		t.Fatal("Blocking GetVersion Failure", err, status.String(), version)
+	}
+
+	// DELETE with stale version should fail
+	status, err = blockConn.Delete(&entry)
+	if !IsVersionMismatch(err) || status.Code != RemoteVersionMismatch {
+		t.Fatal("Blocking Delete expected VersionMismatchError", err, status.String())
+	}
+
+	entry.Version = []byte("v2")
+	status, err = blockConn.Delete(&entry)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Delete Failure", err, status.String())
+	}
+}
+
+func TestBlockGetContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, status, err := blockConn.GetContext(ctx, []byte("object000"))
+	if err != nil || (status.Code != OK && status.Code != RemoteNotFound) {
+		t.Fatal("Blocking GetContext Failure", err, status.String())
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, status, err = blockConn.GetContext(ctx, []byte("object000"))
+	if err != context.Canceled || status.Code != ClientRequestCanceled {
+		t.Fatal("Blocking GetContext expected ClientRequestCanceled", err, status.String())
+	}
+}
+
+func TestNonBlockContextCancel(t *testing.T) {
+	conn, err := NewNonBlockConnection(option)
+	if err != nil {
+		t.Fatal("NonBlocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	callback := &GetCallback{}
+	h := NewResponseHandler(callback)
+	err = conn.GetContext(ctx, []byte("object000"), h)
+	if err != nil {
+		t.Fatal("NonBlocking GetContext Failure", err)
+	}
+	// Cancel before Listen, handler should fail and be removed from queue
+	cancel()
+	h.wait()
+	if callback.Status().Code != ClientRequestCanceled {
+		t.Fatal("NonBlocking GetContext expected ClientRequestCanceled", callback.Status().String())
+	}
+	conn.service.mapMu.Lock()
+	n := len(conn.service.hmap)
+	conn.service.mapMu.Unlock()
+	if n != 0 {
+		t.Fatal("Canceled handler still in queue")
+	}
+
+	// Connection still usable after cancel
+	ncallback := &GenericCallback{}
+	nh := NewResponseHandler(ncallback)
+	if err = conn.NoOp(nh); err != nil {
+		t.Fatal("NonBlocking NoOp Failure", err)
+	}
+	if err = conn.Listen(nh); err != nil || ncallback.Status().Code != OK {
+		t.Fatal("NonBlocking NoOp Failure", err, ncallback.Status().String())
+	}
+}
+
+func TestConnectionTimeoutIsolation(t *testing.T) {
+	slow := int32(0)
+	short := option
+	short.RequestTimeout = 200
+	short.ReceiveLoop = true
+	short.Dialer = slowDialer(&slow)
+	conn1, err := NewNonBlockConnection(short)
+	if err != nil {
+		t.Fatal("NonBlocking connection Failure", err)
+	}
+	defer conn1.Close()
+
+	long := short
+	long.RequestTimeout = 120000
+	conn2, err := NewNonBlockConnection(long)
+	if err != nil {
+		t.Fatal("NonBlocking connection Failure", err)
+	}
+	defer conn2.Close()
+
+	if conn1.service.reqTimeout != 200*time.Millisecond {
+		t.Fatal("Request timeout changed by other connection", conn1.service.reqTimeout)
+	}
+	if conn2.service.reqTimeout != 120*time.Second {
+		t.Fatal("Request timeout not set", conn2.service.reqTimeout)
+	}
+
+	// Response takes at least one slow read, longer than the short request timeout
+	atomic.StoreInt32(&slow, 1)
+	callback := &GenericCallback{}
+	h := NewResponseHandler(callback)
+	if err = conn1.NoOp(h); err != nil {
+		t.Fatal("NonBlocking NoOp Failure", err)
+	}
+	if err = conn1.Listen(h); err != nil || callback.Status().Code != ClientRequestTimeout {
+		t.Fatal("NonBlocking NoOp expected ClientRequestTimeout", err, callback.Status().String())
+	}
+
+	callback = &GenericCallback{}
+	h = NewResponseHandler(callback)
+	if err = conn2.NoOp(h); err != nil {
+		t.Fatal("NonBlocking NoOp Failure", err)
+	}
+	if err = conn2.Listen(h); err != nil || callback.Status().Code != OK {
+		t.Fatal("NonBlocking NoOp Failure", err, callback.Status().String())
+	}
+
+	// Request deadline from context overrides the connection request timeout
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	callback = &GenericCallback{}
+	h = NewResponseHandler(callback)
+	if err = conn1.NoOpContext(ctx, h); err != nil {
+		t.Fatal("NonBlocking NoOpContext Failure", err)
+	}
+	if err = conn1.ListenContext(ctx, h); err != nil || callback.Status().Code != OK {
+		t.Fatal("NonBlocking NoOpContext Failure", err, callback.Status().String())
+	}
+}
+
+// slowConn delays each read while slow is set.
+type slowConn struct {
+	net.Conn
+	slow *int32
+}
+
+func (c *slowConn) Read(b []byte) (int, error) {
+	if atomic.LoadInt32(c.slow) != 0 {
+		time.Sleep(300 * time.Millisecond)
+	}
+	return c.Conn.Read(b)
+}
+
+// slowDialer returns DialFunc making slowConn.
+func slowDialer(slow *int32) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &slowConn{Conn: c, slow: slow}, nil
+	}
+}
+
+func TestRequestTimeoutNotFatal(t *testing.T) {
+	for _, loop := range []bool{false, true} {
+		slow := int32(1)
+		op := option
+		op.ReceiveLoop = loop
+		op.Dialer = slowDialer(&slow)
+		conn, err := NewBlockConnection(op)
+		if err != nil {
+			t.Fatal("Blocking connection Failure", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, status, _ := conn.GetContext(ctx, []byte("object000"))
+		cancel()
+		if status.Code != ClientRequestTimeout {
+			t.Fatal("Blocking GetContext expected ClientRequestTimeout, ReceiveLoop", loop, status.String())
+		}
+
+		// Request timeout only fails the request, connection still usable
+		atomic.StoreInt32(&slow, 0)
+		if status, err = conn.NoOp(); err != nil || status.Code != OK {
+			t.Fatal("Blocking NoOp after request timeout Failure, ReceiveLoop", loop, err, status.String())
+		}
+		conn.Close()
+	}
+}
+
+func TestNonBlockListenContextCancel(t *testing.T) {
+	slow := int32(1)
+	op := option
+	op.Dialer = slowDialer(&slow)
+	conn, err := NewNonBlockConnection(op)
+	if err != nil {
+		t.Fatal("NonBlocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	callback := &GetCallback{}
+	h := NewResponseHandler(callback)
+	if err = conn.GetContext(ctx, []byte("object000"), h); err != nil {
+		t.Fatal("NonBlocking GetContext Failure", err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	// Handler may already fail by submitContext when ListenContext sees ctx done
+	err = conn.ListenContext(ctx, h)
+	if (err != nil && err != context.Canceled) || callback.Status().Code != ClientRequestCanceled {
+		t.Fatal("NonBlocking ListenContext expected ClientRequestCanceled", err, callback.Status().String())
+	}
+	if time.Since(start) > 250*time.Millisecond {
+		t.Fatal("NonBlocking ListenContext blocked by network read", time.Since(start))
+	}
+
+	atomic.StoreInt32(&slow, 0)
+	ncallback := &GenericCallback{}
+	nh := NewResponseHandler(ncallback)
+	if err = conn.NoOp(nh); err != nil {
+		t.Fatal("NonBlocking NoOp Failure", err)
+	}
+	if err = conn.Listen(nh); err != nil || ncallback.Status().Code != OK {
+		t.Fatal("NonBlocking NoOp Failure", err, ncallback.Status().String())
+	}
+}
+
+func TestNonBlockConcurrentListen(t *testing.T) {
+	conn, err := NewNonBlockConnection(option)
+	if err != nil {
+		t.Fatal("NonBlocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	const count = 10
+	errc := make(chan error, count)
+	for k := 0; k < count; k++ {
+		go func() {
+			callback := &GenericCallback{}
+			h := NewResponseHandler(callback)
+			if err := conn.NoOp(h); err != nil {
+				errc <- err
+				return
+			}
+			if err := conn.Listen(h); err != nil {
+				errc <- err
+				return
+			}
+			if callback.Status().Code != OK {
+				errc <- callback.Status()
+				return
+			}
+			errc <- nil
+		}()
+	}
+	for k := 0; k < count; k++ {
+		if err = <-errc; err != nil {
+			t.Fatal("NonBlocking NoOp Failure", err)
+		}
+	}
+
+	// No reader left behind holding the connection
+	deadline := time.Now().Add(time.Second)
+	for len(conn.service.listening) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Reader still running after all responses received")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNonBlockReceiveLoop(t *testing.T) {
+	op := option
+	op.ReceiveLoop = true
+	conn, err := NewNonBlockConnection(op)
+	if err != nil {
+		t.Fatal("NonBlocking connection Failure", err)
+	}
+
+	const count = 200
+	callbacks := make([]*GetCallback, count)
+	handlers := make([]*ResponseHandler, count)
+	for i := 0; i < count; i++ {
+		callbacks[i] = &GetCallback{}
+		handlers[i] = NewResponseHandler(callbacks[i])
+		if err = conn.Get([]byte("object000"), handlers[i]); err != nil {
+			t.Fatal("NonBlocking Get Failure", err)
+		}
+	}
+	// All responses handled in background, no Listen required
+	for i := count - 1; i >= 0; i-- {
+		handlers[i].wait()
+		code := callbacks[i].Status().Code
+		if code != OK && code != RemoteNotFound {
+			t.Fatal("NonBlocking Get Failure", callbacks[i].Status().String())
+		}
+	}
+
+	conn.Close()
+	callback := &GenericCallback{}
+	h := NewResponseHandler(callback)
+	if err = conn.NoOp(h); err == nil {
+		t.Fatal("NonBlocking NoOp expected failure after Close")
+	}
+}
+
+func TestNonBlockAsync(t *testing.T) {
+	for _, loop := range []bool{false, true} {
+		op := option
+		op.ReceiveLoop = loop
+		conn, err := NewNonBlockConnection(op)
+		if err != nil {
+			t.Fatal("NonBlocking connection Failure", err)
+		}
+
+		entry := Record{
+			Key:   []byte("async000"),
+			Value: []byte("async value"),
+			Sync:  SyncWriteThrough,
+			Algo:  AlgorithmSHA1,
+			Tag:   []byte(""),
+			Force: true,
+		}
+		if status, err := conn.PutAsync(&entry).Result(); err != nil || status.Code != OK {
+			t.Fatal("NonBlocking PutAsync Failure", err, status.String())
+		}
+
+		getc := conn.GetAsync(entry.Key).Done()
+		verc := conn.GetVersionAsync(entry.Key).Done()
+		noopc := conn.NoOpAsync().Done()
+		for n := 0; n < 3; n++ {
+			// Finished channel set to nil, so each operation finishes exactly once
+			select {
+			case <-getc:
+				getc = nil
+			case <-verc:
+				verc = nil
+			case <-noopc:
+				noopc = nil
+			case <-time.After(10 * time.Second):
+				t.Fatal("NonBlocking async operations not finished")
+			}
+		}
+
+		record, status, err := conn.GetAsync(entry.Key).Result()
+		if err != nil || status.Code != OK || !bytes.Equal(record.Value, entry.Value) {
+			t.Fatal("NonBlocking GetAsync Failure", err, status.String())
+		}
+
+		// Result is safe to call from multiple goroutines
+		meta := conn.GetMetadataAsync(entry.Key)
+		results := make(chan *Record, 2)
+		for n := 0; n < 2; n++ {
+			go func() {
+				record, _, _ := meta.Result()
+				results <- record
+			}()
+		}
+		for n := 0; n < 2; n++ {
+			if record := <-results; record == nil || !record.MetaOnly || len(record.Value) != 0 {
+				t.Fatal("NonBlocking GetMetadataAsync Failure", record)
+			}
+		}
+
+		conn.Close()
+		if _, err = conn.NoOpAsync().Result(); err == nil {
+			t.Fatal("NonBlocking NoOpAsync expected failure after Close")
+		}
+	}
+}
+
+func TestBlockGetMetadata(t *testing.T) {
+	entry := Record{
+		Key:        []byte("metadata000"),
+		Value:      []byte("metadata value"),
+		NewVersion: []byte("v1"),
+		Tag:        []byte("tag"),
+		Sync:       SyncWriteThrough,
+		Algo:       AlgorithmSHA1,
+		Force:      true,
+	}
+	status, err := blockConn.Put(&entry)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+
+	record, status, err := blockConn.GetMetadata(entry.Key)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking GetMetadata Failure", err, status.String())
+	}
+	if len(record.Value) != 0 || !record.MetaOnly {
+		t.Fatal("Blocking GetMetadata returned value", string(record.Value))
+	}
+	if !bytes.Equal(record.Key, entry.Key) || !bytes.Equal(record.Version, entry.NewVersion) ||
+		!bytes.Equal(record.Tag, entry.Tag) || record.Algo != entry.Algo {
+		t.Fatal("Blocking GetMetadata metadata mismatch", record)
+	}
+
+	record, status, err = blockConn.GetNextMetadata([]byte("metadata"))
+	if err != nil || status.Code != OK || !bytes.Equal(record.Key, entry.Key) || len(record.Value) != 0 {
+		t.Fatal("Blocking GetNextMetadata Failure", err, status.String())
+	}
+}
+
 func TestBlockGetKeyRange(t *testing.T) {
 	r := KeyRange{
 		StartKey:          []byte("object000"),
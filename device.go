@@ -0,0 +1,60 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+// DeviceInfo is the kinetic device information received in connection handshake,
+// so no GetLog is needed right after connected. Log has Configuration and Limits only.
+type DeviceInfo struct {
+	Log
+	ConnectionID   int64 // Connection ID assigned by device
+	ClusterVersion int64 // Cluster version sent with requests
+}
+
+// deviceInfo returns copy of device information from the latest handshake.
+func (ns *networkService) deviceInfo() *DeviceInfo {
+	ns.stateMu.Lock()
+	defer ns.stateMu.Unlock()
+
+	info := &DeviceInfo{
+		ConnectionID:   ns.connID,
+		ClusterVersion: ns.clusterVersion,
+	}
+	if ns.device.Configuration != nil {
+		conf := *ns.device.Configuration
+		info.Configuration = &conf
+	}
+	if ns.device.Limits != nil {
+		limits := *ns.device.Limits
+		info.Limits = &limits
+	}
+	return info
+}
+
+// DeviceInfo returns kinetic device information from connection handshake: vendor, model, world wide name,
+// serial number, firmware and protocol version, ports, power level and limits, together with connection ID
+// and cluster version. Information is refreshed after reconnected.
+func (conn *NonBlockConnection) DeviceInfo() *DeviceInfo {
+	return conn.service.deviceInfo()
+}
+
+// DeviceInfo returns kinetic device information from connection handshake: vendor, model, world wide name,
+// serial number, firmware and protocol version, ports, power level and limits, together with connection ID
+// and cluster version. Information is refreshed after reconnected.
+func (conn *BlockConnection) DeviceInfo() *DeviceInfo {
+	return conn.nbc.DeviceInfo()
+}
@@ -0,0 +1,56 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeviceInfo(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	info := conn.DeviceInfo()
+	if info.Configuration == nil || info.Limits == nil || info.ConnectionID <= 0 {
+		t.Fatal("DeviceInfo missing handshake information", info.ConnectionID)
+	}
+
+	klogs, status, err := conn.GetLog([]LogType{LogTypeConfiguration, LogTypeLimits})
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking GetLog Failure", err, status.String())
+	}
+	if info.Configuration.Vendor != klogs.Configuration.Vendor ||
+		info.Configuration.Model != klogs.Configuration.Model ||
+		!bytes.Equal(info.Configuration.SerialNumber, klogs.Configuration.SerialNumber) ||
+		!bytes.Equal(info.Configuration.WorldWideName, klogs.Configuration.WorldWideName) ||
+		info.Configuration.TLSPort != klogs.Configuration.TLSPort ||
+		*info.Limits != *klogs.Limits {
+		t.Fatal("DeviceInfo not match GetLog")
+	}
+
+	// Returned copy doesn't change connection state
+	info.Limits.MaxKeySize = 1
+	conn.SetClientClusterVersion(info.ClusterVersion + 1)
+	if conn.DeviceInfo().Limits.MaxKeySize == 1 || conn.DeviceInfo().ClusterVersion != info.ClusterVersion+1 {
+		t.Fatal("DeviceInfo Failure after update")
+	}
+}
@@ -0,0 +1,128 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Kinetic/kinetic-go/simulator"
+)
+
+// pipeDialer returns DialFunc connecting to address over in-memory net.Pipe proxied to TCP.
+func pipeDialer(dialed *string) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		*dialed = address
+		tcp, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		client, server := net.Pipe()
+		proxy := func(dst, src net.Conn) {
+			io.Copy(dst, src)
+			dst.Close()
+			src.Close()
+		}
+		go proxy(tcp, server)
+		go proxy(server, tcp)
+		return client, nil
+	}
+}
+
+// faultConn fails writes once broken is set.
+type faultConn struct {
+	net.Conn
+	broken *int32
+}
+
+func (c *faultConn) Write(b []byte) (int, error) {
+	if atomic.LoadInt32(c.broken) != 0 {
+		return 0, errors.New("injected write fault")
+	}
+	return c.Conn.Write(b)
+}
+
+func TestDialer(t *testing.T) {
+	var dialed string
+	op := option
+	op.Dialer = pipeDialer(&dialed)
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection over net.Pipe Failure", err)
+	}
+	defer conn.Close()
+	if dialed != net.JoinHostPort(op.Host, strconv.Itoa(op.Port)) {
+		t.Fatal("Dialer called with unexpected address", dialed)
+	}
+	if status, err := conn.NoOp(); err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp over net.Pipe Failure", err, status.String())
+	}
+
+	var broken int32
+	op.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &faultConn{Conn: c, broken: &broken}, nil
+	}
+	faulty, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer faulty.Close()
+	atomic.StoreInt32(&broken, 1)
+	if _, err = faulty.NoOp(); err == nil {
+		t.Fatal("NoOp with injected write fault expect error")
+	}
+	if faulty.State() != StateFailed {
+		t.Fatal("Connection state expect FAILED, actual", faulty.State().String())
+	}
+
+	op.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errors.New("no route")
+	}
+	if _, err = NewBlockConnection(op); err == nil {
+		t.Fatal("Connection with failing Dialer expect error")
+	}
+}
+
+func TestDialIPv6(t *testing.T) {
+	sim, err := simulator.New(simulator.Options{Host: "::1"})
+	if err != nil {
+		t.Skip("IPv6 loopback not available", err)
+	}
+	defer sim.Close()
+
+	op := option
+	op.Host = "::1"
+	op.Port = sim.Port()
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection to IPv6 address Failure", err)
+	}
+	defer conn.Close()
+	if status, err := conn.NoOp(); err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp Failure", err, status.String())
+	}
+}
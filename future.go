@@ -0,0 +1,223 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+// future holds the ResponseHandler of an asynchronous operation.
+type future struct {
+	h   *ResponseHandler
+	err error // Error when submit the operation
+}
+
+// Wait blocks until the operation finished.
+func (f *future) Wait() {
+	f.h.wait()
+}
+
+// Done returns a channel which is closed when the operation finished.
+func (f *future) Done() <-chan struct{} {
+	return f.h.done
+}
+
+// async submits operation with a new ResponseHandler for callback.
+// Without ClientOptions.ReceiveLoop, a listen is fired for each operation,
+// so the future will finish without calling Listen.
+func (conn *NonBlockConnection) async(callback Callback, submit func(h *ResponseHandler) error) future {
+	h := NewResponseHandler(callback)
+	err := submit(h)
+	if err != nil {
+		// Handler may already failed by network service, fail does nothing in that case
+		h.fail(Status{Code: ClientInternalError, ErrorMsg: err.Error()})
+		return future{h: h, err: err}
+	}
+
+	if !conn.service.loop {
+		go conn.service.listen()
+	}
+
+	return future{h: h}
+}
+
+// StatusFuture is the pending result of operation which doesn't require data from kinetic device.
+type StatusFuture struct {
+	future
+	callback *GenericCallback
+	entry    *Record // Object of PUT / DELETE, for VersionMismatchError
+}
+
+// Result waits for the operation finished, and returns its Status.
+// For PutAsync and DeleteAsync, error is VersionMismatchError if Status.Code is RemoteVersionMismatch.
+func (f *StatusFuture) Result() (Status, error) {
+	f.Wait()
+	if f.err != nil || f.entry == nil {
+		return f.callback.Status(), f.err
+	}
+	return f.callback.Status(), versionMismatchError(f.entry, f.callback.Status(), nil)
+}
+
+// GetFuture is the pending result of GET / GETNEXT / GETPREVIOUS operation.
+type GetFuture struct {
+	future
+	callback *GetCallback
+}
+
+// Result waits for the operation finished, and returns the object Record and Status.
+func (f *GetFuture) Result() (*Record, Status, error) {
+	f.Wait()
+	if f.err != nil {
+		return nil, f.callback.Status(), f.err
+	}
+	return &f.callback.Entry, f.callback.Status(), nil
+}
+
+// GetKeyRangeFuture is the pending result of GETKEYRANGE operation.
+type GetKeyRangeFuture struct {
+	future
+	callback *GetKeyRangeCallback
+}
+
+// Result waits for the operation finished, and returns list of objects' keys and Status.
+func (f *GetKeyRangeFuture) Result() ([][]byte, Status, error) {
+	f.Wait()
+	return f.callback.Keys, f.callback.Status(), f.err
+}
+
+// GetVersionFuture is the pending result of GETVERSION operation.
+type GetVersionFuture struct {
+	future
+	callback *GetVersionCallback
+}
+
+// Result waits for the operation finished, and returns object version and Status.
+func (f *GetVersionFuture) Result() ([]byte, Status, error) {
+	f.Wait()
+	return f.callback.Version, f.callback.Status(), f.err
+}
+
+// GetLogFuture is the pending result of GETLOG operation.
+type GetLogFuture struct {
+	future
+	callback *GetLogCallback
+}
+
+// Result waits for the operation finished, and returns device Log and Status.
+func (f *GetLogFuture) Result() (*Log, Status, error) {
+	f.Wait()
+	if f.err != nil {
+		return nil, f.callback.Status(), f.err
+	}
+	return &f.callback.Logs, f.callback.Status(), nil
+}
+
+// NoOpAsync is NoOp returns StatusFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) NoOpAsync() *StatusFuture {
+	callback := &GenericCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.NoOp(h)
+	})
+	return &StatusFuture{future: f, callback: callback}
+}
+
+// GetAsync is Get returns GetFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) GetAsync(key []byte) *GetFuture {
+	callback := &GetCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.Get(key, h)
+	})
+	return &GetFuture{future: f, callback: callback}
+}
+
+// GetMetadataAsync is GetMetadata returns GetFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) GetMetadataAsync(key []byte) *GetFuture {
+	callback := &GetCallback{metaOnly: true}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.GetMetadata(key, h)
+	})
+	return &GetFuture{future: f, callback: callback}
+}
+
+// GetNextAsync is GetNext returns GetFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) GetNextAsync(key []byte) *GetFuture {
+	callback := &GetCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.GetNext(key, h)
+	})
+	return &GetFuture{future: f, callback: callback}
+}
+
+// GetPreviousAsync is GetPrevious returns GetFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) GetPreviousAsync(key []byte) *GetFuture {
+	callback := &GetCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.GetPrevious(key, h)
+	})
+	return &GetFuture{future: f, callback: callback}
+}
+
+// GetKeyRangeAsync is GetKeyRange returns GetKeyRangeFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) GetKeyRangeAsync(r *KeyRange) *GetKeyRangeFuture {
+	callback := &GetKeyRangeCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.GetKeyRange(r, h)
+	})
+	return &GetKeyRangeFuture{future: f, callback: callback}
+}
+
+// GetVersionAsync is GetVersion returns GetVersionFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) GetVersionAsync(key []byte) *GetVersionFuture {
+	callback := &GetVersionCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.GetVersion(key, h)
+	})
+	return &GetVersionFuture{future: f, callback: callback}
+}
+
+// FlushAsync is Flush returns StatusFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) FlushAsync() *StatusFuture {
+	callback := &GenericCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.Flush(h)
+	})
+	return &StatusFuture{future: f, callback: callback}
+}
+
+// DeleteAsync is Delete returns StatusFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) DeleteAsync(entry *Record) *StatusFuture {
+	callback := &GenericCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.Delete(entry, h)
+	})
+	return &StatusFuture{future: f, callback: callback, entry: entry}
+}
+
+// PutAsync is Put returns StatusFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) PutAsync(entry *Record) *StatusFuture {
+	callback := &GenericCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.Put(entry, h)
+	})
+	return &StatusFuture{future: f, callback: callback, entry: entry}
+}
+
+// GetLogAsync is GetLog returns GetLogFuture instead of calling ResponseHandler.
+func (conn *NonBlockConnection) GetLogAsync(logs []LogType) *GetLogFuture {
+	callback := &GetLogCallback{}
+	f := conn.async(callback, func(h *ResponseHandler) error {
+		return conn.GetLog(logs, h)
+	})
+	return &GetLogFuture{future: f, callback: callback}
+}
@@ -18,7 +18,9 @@
 package kinetic
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	kproto "github.com/Kinetic/kinetic-go/proto"
 )
@@ -27,52 +29,94 @@ import (
 // For each operation, a unique ResponseHandler is required
 type ResponseHandler struct {
 	callback Callback
-	done     bool
-	cond     *sync.Cond
+	mu       sync.Mutex
+	finished bool
+	done     chan struct{} // Closed when response handled or operation failed
+	seq      int64         // Sequence number of the message this handler waiting for
+	deadline time.Time     // Time by which the response is expected
+	timer    *time.Timer   // Fails the handler when deadline passed
+
+	// Request message kept for resubmit after reconnect
+	msg *kproto.Message
+	cmd *kproto.Command
+
+	verify bool // Verify value integrity tag in response
+}
+
+// finish runs f and marks handler as done, only the first call takes effect.
+// Returns false if the handler already finished.
+func (h *ResponseHandler) finish(f func()) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.finished {
+		return false
+	}
+	f()
+	h.finished = true
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	close(h.done)
+	return true
+}
+
+// expire arms timer to call f after d unless handler finished before,
+// any earlier timer is stopped.
+func (h *ResponseHandler) expire(d time.Duration, f func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.finished {
+		return
+	}
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	h.timer = time.AfterFunc(d, f)
 }
 
 func (h *ResponseHandler) handle(cmd *kproto.Command, value []byte) error {
-	if h.callback != nil {
-		if cmd.Status != nil && cmd.Status.Code != nil {
-			if cmd.GetStatus().GetCode() == kproto.Command_Status_SUCCESS {
-				h.callback.Success(cmd, value)
+	h.finish(func() {
+		if h.callback != nil {
+			if cmd.Status != nil && cmd.Status.Code != nil {
+				if cmd.GetStatus().GetCode() == kproto.Command_Status_SUCCESS {
+					h.callback.Success(cmd, value)
+				} else {
+					h.callback.Failure(cmd, getStatusFromProto(cmd))
+				}
 			} else {
-				h.callback.Failure(cmd, getStatusFromProto(cmd))
+				klog.Warn("Other status received")
+				klog.Infof("%v", cmd)
 			}
-		} else {
-			klog.Warn("Other status received")
-			klog.Info("%v", cmd)
 		}
-
-	}
-	h.cond.L.Lock()
-	h.done = true
-	h.cond.Signal()
-	h.cond.L.Unlock()
+	})
 	return nil
 }
 
-func (h *ResponseHandler) fail(s Status) {
-	if h.callback != nil {
-		h.callback.Failure(nil, s)
-	}
-	h.cond.L.Lock()
-	h.done = true
-	h.cond.Signal()
-	h.cond.L.Unlock()
+func (h *ResponseHandler) fail(s Status) bool {
+	return h.finish(func() {
+		if h.callback != nil {
+			h.callback.Failure(nil, s)
+		}
+	})
 }
 
 func (h *ResponseHandler) wait() {
-	h.cond.L.Lock()
-	if h.done == false {
-		h.cond.Wait()
+	<-h.done
+}
+
+// waitContext waits for handler done or ctx done, returns false if ctx done first.
+func (h *ResponseHandler) waitContext(ctx context.Context) bool {
+	select {
+	case <-h.done:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-	h.cond.L.Unlock()
 }
 
 // NewResponseHandler is helper function to build a ResponseHandler with call as the Callback.
 // For each operation, a unique ResponseHandler is required
 func NewResponseHandler(call Callback) *ResponseHandler {
-	h := &ResponseHandler{callback: call, done: false, cond: sync.NewCond(&sync.Mutex{})}
+	h := &ResponseHandler{callback: call, done: make(chan struct{})}
 	return h
 }
@@ -0,0 +1,97 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/crc64"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+var (
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+	crc64Table  = crc64.MakeTable(crc64.ECMA)
+)
+
+// ComputeTag computes the integrity tag of value by algo.
+// SHA2 is SHA-256. CRC32C (Castagnoli), CRC64 (ECMA) and CRC32 (IEEE) checksums are encoded
+// in big endian. SHA3 and other algorithms are not supported.
+func ComputeTag(algo Algorithm, value []byte) ([]byte, error) {
+	switch algo {
+	case AlgorithmSHA1:
+		sum := sha1.Sum(value)
+		return sum[:], nil
+	case AlgorithmSHA2:
+		sum := sha256.Sum256(value)
+		return sum[:], nil
+	case AlgorithmCRC32C:
+		tag := make([]byte, 4)
+		binary.BigEndian.PutUint32(tag, crc32.Checksum(value, crc32cTable))
+		return tag, nil
+	case AlgorithmCRC64:
+		tag := make([]byte, 8)
+		binary.BigEndian.PutUint64(tag, crc64.Checksum(value, crc64Table))
+		return tag, nil
+	case AlgorithmCRC32:
+		tag := make([]byte, 4)
+		binary.BigEndian.PutUint32(tag, crc32.ChecksumIEEE(value))
+		return tag, nil
+	}
+	return nil, fmt.Errorf("Can't compute tag, unsupported algorithm %s", algo.String())
+}
+
+// Requests which response value integrity tag can be verified
+var verifiable = map[kproto.Command_MessageType]bool{
+	kproto.Command_GET:         true,
+	kproto.Command_GETNEXT:     true,
+	kproto.Command_GETPREVIOUS: true,
+}
+
+// putTag returns tag to send with PUT of entry. With ClientOptions.ComputeTag, tag is computed
+// by entry.Algo if entry.Tag is empty.
+func (ns *networkService) putTag(entry *Record) ([]byte, error) {
+	if !ns.option.ComputeTag || len(entry.Tag) > 0 || entry.Algo == 0 {
+		return entry.Tag, nil
+	}
+	return ComputeTag(entry.Algo, entry.Value)
+}
+
+// verifyTag checks tag in GET response matches the returned value.
+// Response without tag is not checked.
+func verifyTag(cmd *kproto.Command, value []byte) *Status {
+	kv := cmd.GetBody().GetKeyValue()
+	if len(kv.GetTag()) == 0 {
+		return nil
+	}
+	algo := convertAlgoFromProto(kv.GetAlgorithm())
+	tag, err := ComputeTag(algo, value)
+	if err != nil {
+		return &Status{Code: ClientIntegrityError, ErrorMsg: err.Error()}
+	}
+	if !bytes.Equal(tag, kv.GetTag()) {
+		return &Status{Code: ClientIntegrityError,
+			ErrorMsg: fmt.Sprintf("Value integrity check failed, key %x, %s tag mismatch", kv.GetKey(), algo.String())}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestComputeTag(t *testing.T) {
+	cases := []struct {
+		algo  Algorithm
+		value string
+		tag   string
+	}{
+		{AlgorithmSHA1, "abc", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{AlgorithmSHA2, "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{AlgorithmCRC32C, "123456789", "e3069283"},
+		{AlgorithmCRC64, "123456789", "995dc9bbdf1939fa"},
+		{AlgorithmCRC32, "123456789", "cbf43926"},
+	}
+	for _, c := range cases {
+		tag, err := ComputeTag(c.algo, []byte(c.value))
+		if err != nil || hex.EncodeToString(tag) != c.tag {
+			t.Fatal("ComputeTag Failure", c.algo.String(), hex.EncodeToString(tag), err)
+		}
+	}
+
+	for _, algo := range []Algorithm{AlgorithmSHA3, Algorithm(0)} {
+		if _, err := ComputeTag(algo, []byte("abc")); err == nil {
+			t.Fatal("ComputeTag expected failure for unsupported algorithm", algo.String())
+		}
+	}
+}
+
+func TestBlockIntegrityTag(t *testing.T) {
+	op := option
+	op.ComputeTag = true
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	entry := Record{
+		Key:   []byte("integrity000"),
+		Value: []byte("integrity value"),
+		Sync:  SyncWriteThrough,
+		Algo:  AlgorithmCRC32C,
+		Force: true,
+	}
+	status, err := conn.Put(&entry)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+
+	record, status, err := conn.Get(entry.Key)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Get Failure", err, status.String())
+	}
+	tag, _ := ComputeTag(AlgorithmCRC32C, entry.Value)
+	if !bytes.Equal(record.Tag, tag) {
+		t.Fatal("Tag not computed on PUT", hex.EncodeToString(record.Tag))
+	}
+
+	// Tag not match value, as if value corrupted on device
+	entry.Tag = []byte{0, 0, 0, 0}
+	status, err = conn.Put(&entry)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+	_, status, err = conn.Get(entry.Key)
+	if err != nil || status.Code != ClientIntegrityError {
+		t.Fatal("Blocking Get expected ClientIntegrityError", err, status.String())
+	}
+
+	// Tag can't be computed for unsupported algorithm
+	entry.Tag = nil
+	entry.Algo = AlgorithmSHA3
+	status, err = conn.Put(&entry)
+	if err == nil || status.Code != ClientInternalError {
+		t.Fatal("Blocking Put expected ClientInternalError for SHA3", err, status.String())
+	}
+}
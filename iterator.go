@@ -0,0 +1,247 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+// DefaultKeyRangeCount is default number of keys for each GETKEYRANGE page,
+// if kinetic device doesn't report MaxKeyRangeCount limit.
+const DefaultKeyRangeCount = 200
+
+// KeyIterator iterates keys within KeyRange, in page of KeyRange.Max keys fetched by GETKEYRANGE.
+// Pages are fetched transparently, and optionally prefetched while keys of current page iterated.
+//
+//	// conn is *BlockConnection, NonBlockConnection.Keys also takes prefetch
+//	it := conn.Keys(&KeyRange{StartKey: start, EndKey: end, StartKeyInclusive: true})
+//	for it.Next() {
+//		key := it.Key()
+//	}
+//	if it.Err() != nil {
+//	}
+type KeyIterator struct {
+	conn     *NonBlockConnection
+	r        KeyRange // Range of next page
+	prefetch bool
+	pending  *GetKeyRangeFuture // Page being fetched
+	more     bool               // More pages after current page
+	page     [][]byte
+	pos      int
+	key      []byte
+	err      error
+}
+
+func newKeyIterator(conn *NonBlockConnection, r *KeyRange, prefetch bool) *KeyIterator {
+	it := &KeyIterator{conn: conn, r: *r, prefetch: prefetch, more: true}
+	if it.r.Max <= 0 {
+		it.r.Max = conn.service.maxKeyRangeCount()
+	}
+	return it
+}
+
+// Keys returns KeyIterator of keys within r, r.Max is the number of keys for each page.
+// If r.Max is 0, MaxKeyRangeCount of device is used. If prefetch is true, next page is
+// requested as soon as current page received.
+func (conn *NonBlockConnection) Keys(r *KeyRange, prefetch bool) *KeyIterator {
+	return newKeyIterator(conn, r, prefetch)
+}
+
+// Keys returns KeyIterator of keys within r, r.Max is the number of keys for each page.
+// If r.Max is 0, MaxKeyRangeCount of device is used.
+func (conn *BlockConnection) Keys(r *KeyRange) *KeyIterator {
+	return newKeyIterator(conn.nbc, r, false)
+}
+
+// fetch requests next page.
+func (it *KeyIterator) fetch() {
+	r := it.r
+	it.pending = it.conn.GetKeyRangeAsync(&r)
+}
+
+// load waits for the page being fetched, and moves range to the keys after this page.
+func (it *KeyIterator) load() {
+	keys, status, err := it.pending.Result()
+	it.pending = nil
+	if err != nil {
+		it.err = err
+		return
+	}
+	if status.Code != OK {
+		it.err = status
+		return
+	}
+
+	it.page, it.pos = keys, 0
+	// Device may return less than Max keys in a page, only empty page is the end
+	it.more = len(keys) > 0
+	if !it.more {
+		return
+	}
+
+	last := keys[len(keys)-1]
+	if it.r.Reverse {
+		it.r.EndKey, it.r.EndKeyInclusive = last, false
+	} else {
+		it.r.StartKey, it.r.StartKeyInclusive = last, false
+	}
+	if it.prefetch {
+		it.fetch()
+	}
+}
+
+// Next moves to the next key, returns false if no more keys or error happened.
+func (it *KeyIterator) Next() bool {
+	for it.pos >= len(it.page) {
+		if it.err != nil {
+			return false
+		}
+		if it.pending == nil {
+			if !it.more {
+				return false
+			}
+			it.fetch()
+		}
+		it.load()
+	}
+
+	it.key = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+// Key returns the current key.
+func (it *KeyIterator) Key() []byte {
+	return it.key
+}
+
+// Err returns the error stopped iteration, nil if all keys iterated.
+func (it *KeyIterator) Err() error {
+	return it.err
+}
+
+// DefaultScanOutstanding is default max outstanding GET requests of RecordIterator,
+// if kinetic device doesn't report MaxOutstandingReadRequests limit.
+const DefaultScanOutstanding = 16
+
+// ScanOptions specify how RecordIterator reads objects.
+type ScanOptions struct {
+	Outstanding int  // Max outstanding GET requests, 0 to use device MaxOutstandingReadRequests limit
+	MetaOnly    bool // Get object metadata only, without object value
+}
+
+// RecordIterator iterates objects within KeyRange in key order. Keys are fetched by KeyIterator,
+// and objects are read by pipelined GET requests, up to ScanOptions.Outstanding at the same time.
+// Object deleted after its key listed is skipped.
+type RecordIterator struct {
+	conn     *NonBlockConnection
+	keys     *KeyIterator
+	metaOnly bool
+	max      int
+	window   []*GetFuture // GET requests outstanding, in key order
+	record   *Record
+	err      error
+	closed   bool
+}
+
+func newRecordIterator(conn *NonBlockConnection, r *KeyRange, op ScanOptions) *RecordIterator {
+	max := op.Outstanding
+	if max <= 0 {
+		max = DefaultScanOutstanding
+		if limits := conn.service.limits(); limits != nil && limits.MaxOutstandingReadRequests > 1 {
+			// Leave one for GETKEYRANGE prefetch
+			max = int(limits.MaxOutstandingReadRequests) - 1
+		}
+	}
+	return &RecordIterator{
+		conn:     conn,
+		keys:     newKeyIterator(conn, r, true),
+		metaOnly: op.MetaOnly,
+		max:      max,
+	}
+}
+
+// Scan returns RecordIterator of objects within r, r.Max is the number of keys for each GETKEYRANGE page.
+func (conn *NonBlockConnection) Scan(r *KeyRange, op ScanOptions) *RecordIterator {
+	return newRecordIterator(conn, r, op)
+}
+
+// Scan returns RecordIterator of objects within r, r.Max is the number of keys for each GETKEYRANGE page.
+func (conn *BlockConnection) Scan(r *KeyRange, op ScanOptions) *RecordIterator {
+	return newRecordIterator(conn.nbc, r, op)
+}
+
+// fill sends GET requests for next keys until window is full.
+func (it *RecordIterator) fill() {
+	for len(it.window) < it.max && it.keys.Next() {
+		if it.metaOnly {
+			it.window = append(it.window, it.conn.GetMetadataAsync(it.keys.Key()))
+		} else {
+			it.window = append(it.window, it.conn.GetAsync(it.keys.Key()))
+		}
+	}
+}
+
+// Next moves to the next object, returns false if no more objects, error happened or iterator closed.
+func (it *RecordIterator) Next() bool {
+	for !it.closed && it.err == nil {
+		it.fill()
+		if len(it.window) == 0 {
+			it.err = it.keys.Err()
+			return false
+		}
+
+		f := it.window[0]
+		it.window = it.window[1:]
+		record, status, err := f.Result()
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if status.Code == RemoteNotFound {
+			// Deleted after key listed
+			continue
+		}
+		if status.Code != OK {
+			it.err = status
+			return false
+		}
+		it.record = record
+		return true
+	}
+	return false
+}
+
+// Record returns the current object.
+func (it *RecordIterator) Record() *Record {
+	return it.record
+}
+
+// Err returns the error stopped iteration, nil if all objects iterated or iterator closed.
+func (it *RecordIterator) Err() error {
+	return it.err
+}
+
+// Close stops iteration before all objects iterated, and waits for outstanding GET requests.
+func (it *RecordIterator) Close() {
+	it.closed = true
+	for _, f := range it.window {
+		f.Wait()
+	}
+	it.window = nil
+	if it.keys.pending != nil {
+		it.keys.pending.Wait()
+		it.keys.pending = nil
+	}
+}
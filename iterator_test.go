@@ -0,0 +1,169 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+func putIterKeys(t *testing.T, conn *BlockConnection, prefix string, count int) [][]byte {
+	keys := make([][]byte, count)
+	for k := 0; k < count; k++ {
+		keys[k] = []byte(fmt.Sprintf("%s%03d", prefix, k))
+		entry := Record{Key: keys[k], Value: keys[k], Sync: SyncWriteBack, Force: true}
+		status, err := conn.Put(&entry)
+		if err != nil || status.Code != OK {
+			t.Fatal("Blocking Put Failure", err, status.String())
+		}
+	}
+	return keys
+}
+
+func checkKeyIterator(t *testing.T, it *KeyIterator, expect [][]byte) {
+	n := 0
+	for it.Next() {
+		if n >= len(expect) || !bytes.Equal(it.Key(), expect[n]) {
+			t.Fatal("KeyIterator unexpected key", n, string(it.Key()))
+		}
+		n++
+	}
+	if it.Err() != nil || n != len(expect) {
+		t.Fatal("KeyIterator Failure", it.Err(), n)
+	}
+}
+
+func TestKeyIterator(t *testing.T) {
+	bconn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer bconn.Close()
+	keys := putIterKeys(t, bconn, "iter", 25)
+
+	r := KeyRange{
+		StartKey:          []byte("iter"),
+		EndKey:            []byte("iter999"),
+		StartKeyInclusive: true,
+		EndKeyInclusive:   true,
+		Max:               7,
+	}
+	checkKeyIterator(t, bconn.Keys(&r), keys)
+
+	reversed := make([][]byte, len(keys))
+	for k := range keys {
+		reversed[len(keys)-1-k] = keys[k]
+	}
+	rr := r
+	rr.Reverse = true
+	checkKeyIterator(t, bconn.Keys(&rr), reversed)
+
+	conn, err := NewNonBlockConnection(option)
+	if err != nil {
+		t.Fatal("NonBlocking connection Failure", err)
+	}
+	defer conn.Close()
+	checkKeyIterator(t, conn.Keys(&r, true), keys)
+	checkKeyIterator(t, conn.Keys(&rr, true), reversed)
+
+	// Exclusive start key, page size equals number of keys
+	r.StartKey, r.StartKeyInclusive, r.Max = keys[0], false, 24
+	checkKeyIterator(t, conn.Keys(&r, true), keys[1:])
+}
+
+// keyRangePage returns finished GetKeyRangeFuture with keys.
+func keyRangePage(keys ...string) *GetKeyRangeFuture {
+	callback := &GetKeyRangeCallback{}
+	h := NewResponseHandler(callback)
+	cmd := &kproto.Command{
+		Status: &kproto.Command_Status{Code: kproto.Command_Status_SUCCESS.Enum()},
+		Body:   &kproto.Command_Body{Range: &kproto.Command_Range{}},
+	}
+	for _, key := range keys {
+		cmd.Body.Range.Keys = append(cmd.Body.Range.Keys, []byte(key))
+	}
+	h.handle(cmd, nil)
+	return &GetKeyRangeFuture{future: future{h: h}, callback: callback}
+}
+
+func TestKeyIteratorShortPage(t *testing.T) {
+	it := &KeyIterator{r: KeyRange{StartKey: []byte("a"), StartKeyInclusive: true, Max: 10}, more: true}
+
+	// Page with less than Max keys is not the end
+	it.pending = keyRangePage("a", "b", "c")
+	it.load()
+	if !it.more || string(it.r.StartKey) != "c" || it.r.StartKeyInclusive {
+		t.Fatal("KeyIterator stopped at short page", it.more, string(it.r.StartKey))
+	}
+
+	it.pending = keyRangePage()
+	it.load()
+	if it.more {
+		t.Fatal("KeyIterator not stopped at empty page")
+	}
+}
+
+func TestRecordIterator(t *testing.T) {
+	bconn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer bconn.Close()
+	keys := putIterKeys(t, bconn, "scan", 30)
+
+	r := KeyRange{
+		StartKey:          []byte("scan"),
+		EndKey:            []byte("scan999"),
+		StartKeyInclusive: true,
+		EndKeyInclusive:   true,
+		Max:               7,
+	}
+	it := bconn.Scan(&r, ScanOptions{Outstanding: 4})
+	n := 0
+	for it.Next() {
+		record := it.Record()
+		if !bytes.Equal(record.Key, keys[n]) || !bytes.Equal(record.Value, keys[n]) {
+			t.Fatal("RecordIterator unexpected record", n, string(record.Key))
+		}
+		n++
+	}
+	if it.Err() != nil || n != len(keys) {
+		t.Fatal("RecordIterator Failure", it.Err(), n)
+	}
+
+	// Metadata only, stop early
+	it = bconn.Scan(&r, ScanOptions{MetaOnly: true})
+	for n = 0; n < 5 && it.Next(); n++ {
+		record := it.Record()
+		if !bytes.Equal(record.Key, keys[n]) || len(record.Value) != 0 || !record.MetaOnly {
+			t.Fatal("RecordIterator unexpected metadata", n, string(record.Key))
+		}
+	}
+	it.Close()
+	if it.Next() || it.Err() != nil {
+		t.Fatal("RecordIterator not stopped by Close", it.Err())
+	}
+
+	status, err := bconn.NoOp()
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp after Scan Failure", err, status.String())
+	}
+}
@@ -23,7 +23,9 @@ For details about kinetic protocol, please refer to https://github.com/Kinetic/k
 package kinetic
 
 import (
+	"context"
 	"io"
+	"net"
 	"os"
 
 	kproto "github.com/Kinetic/kinetic-go/proto"
@@ -78,7 +80,30 @@ type ClientOptions struct {
 	UseSSL         bool  // Use SSL connection, or plain connection
 	Timeout        int64 // Network timeout in millisecond
 	RequestTimeout int64 // Operation request timeout in millisecond
-}
+	ReceiveLoop    bool  // Receive responses in a dedicated goroutine, so requests can be pipelined
+	// Reconnect automatically when connection lost, nil to disable. Reconnect implies ReceiveLoop.
+	Reconnect *ReconnectOptions
+	// Compute Record.Tag by Record.Algo on PUT if Tag is empty, and verify the tag
+	// on GET, GETNEXT and GETPREVIOUS. Tag mismatch fails with ClientIntegrityError.
+	// PUT fails with ClientInternalError if ComputeTag doesn't support Record.Algo.
+	ComputeTag bool
+	// Unsolicited status from device is sent to StatusEvents, nil to disable.
+	// Events are dropped if channel is not ready to receive. StatusEvents implies ReceiveLoop,
+	// so status is received and connection State updated without pending requests.
+	StatusEvents chan<- StatusEvent
+	// TLS options to verify device certificate and present client certificate if UseSSL,
+	// nil to connect without verifying device certificate.
+	TLS *TLSOptions
+	// If UseSSL is false, PIN and SECURITY operations are sent over TLS connection to device TLSPort,
+	// opened when first needed. Without AutoTLS, these operations fail on plain connection.
+	AutoTLS bool
+	// Dialer makes network connection to Host and Port, nil to dial TCP. ctx carries the connection
+	// timeout. For UseSSL, TLS handshake is made over the returned connection.
+	Dialer DialFunc
+}
+
+// DialFunc makes network connection to address, as net.Dialer.DialContext does.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
 
 // MessageType defines the top level kinetic command message type.
 type MessageType int32
@@ -544,15 +569,19 @@ func convertPriorityFromProto(p kproto.Command_Priority) Priority {
 }
 
 // Record structure defines information for an object stored on kinetic device.
+// For PUT and DELETE without Force, Version is the version expected on device, and
+// the operation fails with RemoteVersionMismatch if the object on device has a different version.
+// For PUT, NewVersion is the version stored on device with the object.
 type Record struct {
-	Key      []byte
-	Value    []byte
-	Version  []byte
-	Tag      []byte
-	Algo     Algorithm
-	Sync     Synchronization
-	Force    bool
-	MetaOnly bool
+	Key        []byte
+	Value      []byte
+	Version    []byte
+	NewVersion []byte
+	Tag        []byte
+	Algo       Algorithm
+	Sync       Synchronization
+	Force      bool
+	MetaOnly   bool
 }
 
 // KeyRange structure defines the range for GetRange operation.
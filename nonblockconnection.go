@@ -19,6 +19,7 @@ package kinetic
 
 import (
 	"bytes"
+	"context"
 	"sync"
 
 	kproto "github.com/Kinetic/kinetic-go/proto"
@@ -27,10 +28,13 @@ import (
 // NonBlockConnection send kinetic message to devices and doesn't wait for
 // response message from device.
 type NonBlockConnection struct {
-	service    *networkService
-	batchID    uint32 // Current batch Operation ID
-	batchCount int32  // Current batch operation count
-	batchMu    sync.Mutex
+	service  *networkService
+	batchID  uint32          // Last batch Operation ID
+	batch    *Batch          // Batch started by BatchStart, nil if none
+	batchMu  sync.Mutex      // Guards batch
+	batches  chan struct{}   // Slots of Batch open at the same time
+	secure   *networkService // TLS side connection for PIN and SECURITY operations, with AutoTLS
+	secureMu sync.Mutex
 }
 
 // NewNonBlockConnection is helper function to establish non-block connection to device.
@@ -44,19 +48,29 @@ func NewNonBlockConnection(op ClientOptions) (*NonBlockConnection, error) {
 		return nil, err
 	}
 
-	return &NonBlockConnection{service: service, batchID: 0, batchCount: 0}, nil
+	max := DefaultMaxBatchCountPerDevice
+	if limits := service.limits(); limits != nil && limits.MaxBatchCountPerDevice > 0 {
+		max = int(limits.MaxBatchCountPerDevice)
+	}
+
+	return &NonBlockConnection{service: service, batchID: 0, batches: make(chan struct{}, max)}, nil
 }
 
 // NoOp does nothing but wait for drive to return response.
 func (conn *NonBlockConnection) NoOp(h *ResponseHandler) error {
+	return conn.NoOpContext(context.Background(), h)
+}
+
+// NoOpContext is NoOp with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) NoOpContext(ctx context.Context, h *ResponseHandler) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_NOOP)
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submitContext(ctx, msg, cmd, nil, h)
 }
 
-func (conn *NonBlockConnection) get(key []byte, getType kproto.Command_MessageType, h *ResponseHandler) error {
+func (conn *NonBlockConnection) get(ctx context.Context, key []byte, getType kproto.Command_MessageType, metaOnly bool, h *ResponseHandler) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(getType)
@@ -65,27 +79,80 @@ func (conn *NonBlockConnection) get(key []byte, getType kproto.Command_MessageTy
 			Key: key,
 		},
 	}
+	if metaOnly {
+		cmd.Body.KeyValue.MetadataOnly = &metaOnly
+	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submitContext(ctx, msg, cmd, nil, h)
 }
 
 // Get gets the object from kinetic drive with key.
 func (conn *NonBlockConnection) Get(key []byte, h *ResponseHandler) error {
-	return conn.get(key, kproto.Command_GET, h)
+	return conn.get(context.Background(), key, kproto.Command_GET, false, h)
+}
+
+// GetContext is Get with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) GetContext(ctx context.Context, key []byte, h *ResponseHandler) error {
+	return conn.get(ctx, key, kproto.Command_GET, false, h)
 }
 
 // GetNext gets the next object with key after the passed in key.
 func (conn *NonBlockConnection) GetNext(key []byte, h *ResponseHandler) error {
-	return conn.get(key, kproto.Command_GETNEXT, h)
+	return conn.get(context.Background(), key, kproto.Command_GETNEXT, false, h)
+}
+
+// GetNextContext is GetNext with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) GetNextContext(ctx context.Context, key []byte, h *ResponseHandler) error {
+	return conn.get(ctx, key, kproto.Command_GETNEXT, false, h)
 }
 
 // GetPrevious gets the previous object with key before the passed in key.
 func (conn *NonBlockConnection) GetPrevious(key []byte, h *ResponseHandler) error {
-	return conn.get(key, kproto.Command_GETPREVIOUS, h)
+	return conn.get(context.Background(), key, kproto.Command_GETPREVIOUS, false, h)
+}
+
+// GetPreviousContext is GetPrevious with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) GetPreviousContext(ctx context.Context, key []byte, h *ResponseHandler) error {
+	return conn.get(ctx, key, kproto.Command_GETPREVIOUS, false, h)
+}
+
+// GetMetadata gets the object metadata from kinetic drive with key, without object value.
+func (conn *NonBlockConnection) GetMetadata(key []byte, h *ResponseHandler) error {
+	return conn.get(context.Background(), key, kproto.Command_GET, true, h)
+}
+
+// GetMetadataContext is GetMetadata with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) GetMetadataContext(ctx context.Context, key []byte, h *ResponseHandler) error {
+	return conn.get(ctx, key, kproto.Command_GET, true, h)
+}
+
+// GetNextMetadata gets the next object metadata with key after the passed in key, without object value.
+func (conn *NonBlockConnection) GetNextMetadata(key []byte, h *ResponseHandler) error {
+	return conn.get(context.Background(), key, kproto.Command_GETNEXT, true, h)
+}
+
+// GetNextMetadataContext is GetNextMetadata with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) GetNextMetadataContext(ctx context.Context, key []byte, h *ResponseHandler) error {
+	return conn.get(ctx, key, kproto.Command_GETNEXT, true, h)
+}
+
+// GetPreviousMetadata gets the previous object metadata with key before the passed in key, without object value.
+func (conn *NonBlockConnection) GetPreviousMetadata(key []byte, h *ResponseHandler) error {
+	return conn.get(context.Background(), key, kproto.Command_GETPREVIOUS, true, h)
+}
+
+// GetPreviousMetadataContext is GetPreviousMetadata with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) GetPreviousMetadataContext(ctx context.Context, key []byte, h *ResponseHandler) error {
+	return conn.get(ctx, key, kproto.Command_GETPREVIOUS, true, h)
 }
 
 // GetKeyRange gets list of objects' keys, which meet the criteria defined by KeyRange.
 func (conn *NonBlockConnection) GetKeyRange(r *KeyRange, h *ResponseHandler) error {
+	return conn.GetKeyRangeContext(context.Background(), r, h)
+}
+
+// GetKeyRangeContext is GetKeyRange with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) GetKeyRangeContext(ctx context.Context, r *KeyRange, h *ResponseHandler) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_GETKEYRANGE)
@@ -100,11 +167,16 @@ func (conn *NonBlockConnection) GetKeyRange(r *KeyRange, h *ResponseHandler) err
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submitContext(ctx, msg, cmd, nil, h)
 }
 
 // GetVersion gets object DB version information.
 func (conn *NonBlockConnection) GetVersion(key []byte, h *ResponseHandler) error {
+	return conn.GetVersionContext(context.Background(), key, h)
+}
+
+// GetVersionContext is GetVersion with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) GetVersionContext(ctx context.Context, key []byte, h *ResponseHandler) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_GETVERSION)
@@ -114,54 +186,67 @@ func (conn *NonBlockConnection) GetVersion(key []byte, h *ResponseHandler) error
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submitContext(ctx, msg, cmd, nil, h)
 }
 
 // Flush requests kinetic device to write all cached data to persistent media.
 func (conn *NonBlockConnection) Flush(h *ResponseHandler) error {
+	return conn.FlushContext(context.Background(), h)
+}
+
+// FlushContext is Flush with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) FlushContext(ctx context.Context, h *ResponseHandler) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_FLUSHALLDATA)
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submitContext(ctx, msg, cmd, nil, h)
 }
 
-func (conn *NonBlockConnection) delete(entry *Record, batch bool, h *ResponseHandler) error {
+// deleteCommand builds DELETE message of entry.
+func deleteCommand(entry *Record) (*kproto.Message, *kproto.Command) {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_DELETE)
 
-	// Bathc operation, batchID needed
-	if batch {
-		cmd.Header.BatchID = &conn.batchID
-	}
-
 	sync := convertSyncToProto(entry.Sync)
 	//algo := convertAlgoToProto(entry.Algo)
 	cmd.Body = &kproto.Command_Body{
 		KeyValue: &kproto.Command_KeyValue{
 			Key:             entry.Key,
+			DbVersion:       entry.Version,
 			Force:           &entry.Force,
 			Synchronization: &sync,
 			//Algorithm:       &algo,
 		},
 	}
+	return msg, cmd
+}
 
-	return conn.service.submit(msg, cmd, nil, h)
+func (conn *NonBlockConnection) delete(ctx context.Context, entry *Record, h *ResponseHandler) error {
+	msg, cmd := deleteCommand(entry)
+	return conn.service.submitContext(ctx, msg, cmd, nil, h)
 }
 
 // Delete deletes object from kinetic device.
+// If entry.Force is false, object is only deleted when its version on device equals entry.Version.
 func (conn *NonBlockConnection) Delete(entry *Record, h *ResponseHandler) error {
 	// Normal DELETE operation, not batch operation.
-	return conn.delete(entry, false, h)
+	return conn.delete(context.Background(), entry, h)
+}
+
+// DeleteContext is Delete with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) DeleteContext(ctx context.Context, entry *Record, h *ResponseHandler) error {
+	return conn.delete(ctx, entry, h)
 }
 
-func (conn *NonBlockConnection) put(entry *Record, batch bool, h *ResponseHandler) error {
+// putCommand builds PUT message of entry.
+func (conn *NonBlockConnection) putCommand(entry *Record) (*kproto.Message, *kproto.Command, error) {
 	msg := newMessage(kproto.Message_HMACAUTH)
 	cmd := newCommand(kproto.Command_PUT)
 
-	// Bathc operation, batchID needed
-	if batch {
-		cmd.Header.BatchID = &conn.batchID
+	tag, err := conn.service.putTag(entry)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	sync := convertSyncToProto(entry.Sync)
@@ -169,20 +254,38 @@ func (conn *NonBlockConnection) put(entry *Record, batch bool, h *ResponseHandle
 	cmd.Body = &kproto.Command_Body{
 		KeyValue: &kproto.Command_KeyValue{
 			Key:             entry.Key,
+			DbVersion:       entry.Version,
+			NewVersion:      entry.NewVersion,
 			Force:           &entry.Force,
 			Synchronization: &sync,
 			Algorithm:       &algo,
-			Tag:             entry.Tag,
+			Tag:             tag,
 		},
 	}
+	return msg, cmd, nil
+}
 
-	return conn.service.submit(msg, cmd, entry.Value, h)
+func (conn *NonBlockConnection) put(ctx context.Context, entry *Record, h *ResponseHandler) error {
+	msg, cmd, err := conn.putCommand(entry)
+	if err != nil {
+		h.fail(Status{Code: ClientInternalError, ErrorMsg: err.Error()})
+		return err
+	}
+
+	return conn.service.submitContext(ctx, msg, cmd, entry.Value, h)
 }
 
 // Put store object to kinetic device.
+// If entry.Force is false, object is only stored when its version on device equals entry.Version,
+// entry.NewVersion will be the object version after PUT.
 func (conn *NonBlockConnection) Put(entry *Record, h *ResponseHandler) error {
 	// Normal PUT operation, not batch operation
-	return conn.put(entry, false, h)
+	return conn.put(context.Background(), entry, h)
+}
+
+// PutContext is Put with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) PutContext(ctx context.Context, entry *Record, h *ResponseHandler) error {
+	return conn.put(ctx, entry, h)
 }
 
 func (conn *NonBlockConnection) buildP2PMessage(request *P2PPushRequest) *kproto.Command_P2POperation {
@@ -226,65 +329,113 @@ func (conn *NonBlockConnection) P2PPush(request *P2PPushRequest, h *ResponseHand
 
 // BatchStart starts new batch operation, all following batch PUT / DELETE share same batch ID until
 // BatchEnd or BatchAbort is called.
+// Only one such batch can be open on connection at a time, use NewBatch for concurrent batches.
+// BatchStart takes one of MaxBatchCountPerDevice batch slots like NewBatch.
 func (conn *NonBlockConnection) BatchStart(h *ResponseHandler) error {
-	msg := newMessage(kproto.Message_HMACAUTH)
-	cmd := newCommand(kproto.Command_START_BATCH)
+	conn.batchMu.Lock()
+	defer conn.batchMu.Unlock()
+	if conn.batch != nil {
+		h.fail(Status{Code: ClientInternalError, ErrorMsg: errBatchStarted.Error()})
+		return errBatchStarted
+	}
+
+	b, err := conn.openBatch()
+	if err != nil {
+		h.fail(Status{Code: ClientInternalError, ErrorMsg: err.Error()})
+		return err
+	}
+	if err = b.start(h); err != nil {
+		b.close()
+		return err
+	}
+	conn.batch = b
+
+	// Release batch if device failed to start it
+	go func() {
+		h.wait()
+		if h.callback != nil && h.callback.Status().Code == OK {
+			return
+		}
+		conn.batchMu.Lock()
+		if conn.batch == b {
+			conn.batch = nil
+		}
+		conn.batchMu.Unlock()
+		b.mu.Lock()
+		if !b.closed {
+			b.close()
+		}
+		b.mu.Unlock()
+	}()
+	return nil
+}
 
-	// TODO: Need to confirm can start new batch if current one not end / abort yet???
+// startedBatch returns Batch started by BatchStart, and clears it if end is true.
+func (conn *NonBlockConnection) startedBatch(end bool) (*Batch, error) {
 	conn.batchMu.Lock()
-	conn.batchID++
-	conn.batchCount = 0 // Reset
-	conn.batchMu.Unlock()
-	cmd.Header.BatchID = &conn.batchID
-	return conn.service.submit(msg, cmd, nil, h)
+	defer conn.batchMu.Unlock()
+	b := conn.batch
+	if b == nil {
+		return nil, errNoBatch
+	}
+	if end {
+		conn.batch = nil
+	}
+	return b, nil
 }
 
 // BatchPut puts objects to kinetic drive, as a batch job. Batch PUT / DELETE won't expect acknowledgement
 // from kinetic device. Status for batch PUT / DELETE will only available in response message for BatchEnd.
 func (conn *NonBlockConnection) BatchPut(entry *Record) error {
-	// Batch operation PUT
-	conn.batchMu.Lock()
-	conn.batchCount++
-	conn.batchMu.Unlock()
-	return conn.put(entry, true, nil)
+	b, err := conn.startedBatch(false)
+	if err != nil {
+		return err
+	}
+	return b.Put(entry)
 }
 
 // BatchDelete delete object from kinetic drive, as a batch job. Batch PUT / DELETE won't expect acknowledgement
 // from kinetic device. Status for batch PUT / DELETE will only available in response message for BatchEnd.
 func (conn *NonBlockConnection) BatchDelete(entry *Record) error {
-	// Batch operation DELETE
-	conn.batchMu.Lock()
-	conn.batchCount++
-	conn.batchMu.Unlock()
-	return conn.delete(entry, true, nil)
+	b, err := conn.startedBatch(false)
+	if err != nil {
+		return err
+	}
+	return b.Delete(entry)
 }
 
 // BatchEnd commits all batch jobs. Response from kinetic device will indicate succeeded jobs sequence number, or
 // the first failed job sequence number if there is a failure.
 func (conn *NonBlockConnection) BatchEnd(h *ResponseHandler) error {
-	msg := newMessage(kproto.Message_HMACAUTH)
-	cmd := newCommand(kproto.Command_END_BATCH)
-
-	cmd.Header.BatchID = &conn.batchID
-	cmd.Body = &kproto.Command_Body{
-		Batch: &kproto.Command_Batch{
-			Count: &conn.batchCount,
-		},
+	b, err := conn.startedBatch(true)
+	if err != nil {
+		h.fail(Status{Code: ClientInternalError, ErrorMsg: err.Error()})
+		return err
 	}
-	return conn.service.submit(msg, cmd, nil, h)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.end(h)
 }
 
 // BatchAbort aborts jobs in current batch operation.
 func (conn *NonBlockConnection) BatchAbort(h *ResponseHandler) error {
-	msg := newMessage(kproto.Message_HMACAUTH)
-	cmd := newCommand(kproto.Command_ABORT_BATCH)
-
-	cmd.Header.BatchID = &conn.batchID
-	return conn.service.submit(msg, cmd, nil, h)
+	b, err := conn.startedBatch(true)
+	if err != nil {
+		h.fail(Status{Code: ClientInternalError, ErrorMsg: err.Error()})
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.abort(h)
 }
 
 // GetLog gets kinetic device Log information. Can request single LogType or multiple LogType.
 func (conn *NonBlockConnection) GetLog(logs []LogType, h *ResponseHandler) error {
+	return conn.GetLogContext(context.Background(), logs, h)
+}
+
+// GetLogContext is GetLog with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) GetLogContext(ctx context.Context, logs []LogType, h *ResponseHandler) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	types := make([]kproto.Command_GetLog_Type, len(logs))
@@ -298,7 +449,7 @@ func (conn *NonBlockConnection) GetLog(logs []LogType, h *ResponseHandler) error
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submitContext(ctx, msg, cmd, nil, h)
 }
 
 func (conn *NonBlockConnection) pinop(pin []byte, op kproto.Command_PinOperation_PinOpType, h *ResponseHandler) error {
@@ -315,7 +466,7 @@ func (conn *NonBlockConnection) pinop(pin []byte, op kproto.Command_PinOperation
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.submitSecure(msg, cmd, h)
 }
 
 // SecureErase request kinetic device to perform secure erase.
@@ -375,7 +526,14 @@ func (conn *NonBlockConnection) SetClusterVersion(version int64, h *ResponseHand
 
 // SetClientClusterVersion sets the cluster version for all following message to kinetic device.
 func (conn *NonBlockConnection) SetClientClusterVersion(version int64) {
-	conn.service.clusterVersion = version
+	conn.service.setClusterVersion(version)
+
+	// TLS side connection sends messages for this connection too
+	conn.secureMu.Lock()
+	if conn.secure != nil {
+		conn.secure.setClusterVersion(version)
+	}
+	conn.secureMu.Unlock()
 }
 
 // SetLockPin changes kinetic device lock pin. Both current pin and new pin needed.
@@ -391,7 +549,7 @@ func (conn *NonBlockConnection) SetLockPin(currentPin []byte, newPin []byte, h *
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.submitSecure(msg, cmd, h)
 }
 
 // SetErasePin changes kinetic device erase pin. Both current pin and new pin needed.
@@ -407,7 +565,7 @@ func (conn *NonBlockConnection) SetErasePin(currentPin []byte, newPin []byte, h
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.submitSecure(msg, cmd, h)
 }
 
 // SetACL sets Permission for particular user Identity.
@@ -447,13 +605,18 @@ func (conn *NonBlockConnection) SetACL(acls []ACL, h *ResponseHandler) error {
 		},
 	}
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.submitSecure(msg, cmd, h)
 }
 
 // MediaScan is to check that the user data is readable, and
 // if the end to end integrity is known to the device, if the
 // end to end integrity field is correct.
 func (conn *NonBlockConnection) MediaScan(op *MediaOperation, pri Priority, h *ResponseHandler) error {
+	return conn.MediaScanContext(context.Background(), op, pri, h)
+}
+
+// MediaScanContext is MediaScan with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) MediaScanContext(ctx context.Context, op *MediaOperation, pri Priority, h *ResponseHandler) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_MEDIASCAN)
@@ -470,13 +633,18 @@ func (conn *NonBlockConnection) MediaScan(op *MediaOperation, pri Priority, h *R
 	p := convertPriorityToProto(pri)
 	cmd.Header.Priority = &p
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submitContext(ctx, msg, cmd, nil, h)
 }
 
 // MediaOptimize performs optimizations of the media. Things like
 // defragmentation, compaction, garbage collection, compression
 // could be things accomplished using the media optimize command.
 func (conn *NonBlockConnection) MediaOptimize(op *MediaOperation, pri Priority, h *ResponseHandler) error {
+	return conn.MediaOptimizeContext(context.Background(), op, pri, h)
+}
+
+// MediaOptimizeContext is MediaOptimize with ctx to cancel the operation and set its deadline.
+func (conn *NonBlockConnection) MediaOptimizeContext(ctx context.Context, op *MediaOperation, pri Priority, h *ResponseHandler) error {
 	msg := newMessage(kproto.Message_HMACAUTH)
 
 	cmd := newCommand(kproto.Command_MEDIAOPTIMIZE)
@@ -493,7 +661,7 @@ func (conn *NonBlockConnection) MediaOptimize(op *MediaOperation, pri Priority,
 	p := convertPriorityToProto(pri)
 	cmd.Header.Priority = &p
 
-	return conn.service.submit(msg, cmd, nil, h)
+	return conn.service.submitContext(ctx, msg, cmd, nil, h)
 }
 
 // SetPowerLevel sets device power level
@@ -515,13 +683,69 @@ func (conn *NonBlockConnection) SetPowerLevel(p PowerLevel, h *ResponseHandler)
 
 // Listen waits and read response message from device, then call ResponseHandler
 // in queue to process received message.
+// If ClientOptions.ReceiveLoop is set, responses are received in background and
+// Listen only waits for h to finish. Listen is not required for h to be called.
 func (conn *NonBlockConnection) Listen(h *ResponseHandler) error {
-	err := conn.service.listen()
-	h.wait()
-	return err
+	return conn.ListenContext(context.Background(), h)
+}
+
+// ListenContext is Listen with ctx. If ctx is done before h finished, h is removed from queue
+// and fails with ClientRequestCanceled or ClientRequestTimeout status, ctx.Err() is returned.
+func (conn *NonBlockConnection) ListenContext(ctx context.Context, h *ResponseHandler) error {
+	// Response is read in background, so caller returns once h finished or ctx done,
+	// not blocked by network read for other requests. At most one reader runs at a time,
+	// so concurrent Listen calls share it instead of each leaving a reader behind.
+	cancel := func() error {
+		if conn.service.cancel(h, contextStatus(ctx.Err())) {
+			return ctx.Err()
+		}
+		// Response handled before cancel
+		return nil
+	}
+
+	if conn.service.loop {
+		// Responses handled by receiveLoop, only wait for h
+		err := conn.service.listen()
+		if h.waitContext(ctx) {
+			return err
+		}
+		return cancel()
+	}
+
+	errc := make(chan error, 1)
+	reading := false
+	for {
+		start := conn.service.listening
+		if reading {
+			start = nil
+		}
+		select {
+		case start <- struct{}{}:
+			reading = true
+			go func() {
+				err := conn.service.listen()
+				<-conn.service.listening
+				errc <- err
+			}()
+		case err := <-errc:
+			// Packet read may be response for other request, read again until h finished
+			reading = false
+			if err != nil {
+				if h.waitContext(ctx) {
+					return err
+				}
+				return cancel()
+			}
+		case <-h.done:
+			return nil
+		case <-ctx.Done():
+			return cancel()
+		}
+	}
 }
 
 // Close the connection to kientic device
 func (conn *NonBlockConnection) Close() {
 	conn.service.close()
+	conn.closeSecure()
 }
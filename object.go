@@ -0,0 +1,380 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxValueSize is max value size of kinetic device, if device doesn't report MaxValueSize limit.
+const DefaultMaxValueSize = 1024 * 1024
+
+// objectFormat is the manifest format version written by ObjectWriter.
+const objectFormat = 1
+
+// ErrNotObject is returned when value of the key is not an object manifest.
+var ErrNotObject = errors.New("Value is not object manifest")
+
+// ObjectOptions specify how large object is chunked.
+type ObjectOptions struct {
+	ChunkSize int       // Size of each chunk, MaxValueSize of device if 0
+	Algo      Algorithm // Algorithm of chunk tags, AlgorithmSHA1 if 0
+}
+
+// objectManifest is stored as value of object key, it describes the chunks of the object.
+// Chunk i is stored at key chunkKey(key, ID, i), and all chunks except the last one are ChunkSize bytes.
+type objectManifest struct {
+	Format    int       `json:"format"`
+	ID        []byte    `json:"id"`
+	Size      int64     `json:"size"`
+	ChunkSize int64     `json:"chunk_size"`
+	Algo      Algorithm `json:"algorithm"`
+	Tags      [][]byte  `json:"tags"`
+}
+
+// chunkKey returns key of chunk index of object upload id.
+// Chunk keys sort right after object key: key, 0x00, 8 bytes upload id, 4 bytes index.
+func chunkKey(key []byte, id []byte, index int) []byte {
+	ck := make([]byte, len(key)+1+len(id)+4)
+	copy(ck, key)
+	copy(ck[len(key)+1:], id)
+	binary.BigEndian.PutUint32(ck[len(ck)-4:], uint32(index))
+	return ck
+}
+
+// chunkLen returns size of chunk index of object.
+func (m *objectManifest) chunkLen(index int) int64 {
+	n := m.Size - int64(index)*m.ChunkSize
+	if n > m.ChunkSize {
+		n = m.ChunkSize
+	}
+	return n
+}
+
+// manifest reads object manifest of key, and returns it with the version of key.
+// Version is also returned with ErrNotObject if key exists but is not an object.
+func (conn *BlockConnection) manifest(key []byte) (*objectManifest, []byte, error) {
+	entry, status, err := conn.Get(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status.Code != OK {
+		return nil, nil, status
+	}
+	m := &objectManifest{}
+	if err := json.Unmarshal(entry.Value, m); err != nil || m.Format != objectFormat || m.ChunkSize <= 0 ||
+		int64(len(m.Tags)) != (m.Size+m.ChunkSize-1)/m.ChunkSize {
+		return nil, entry.Version, ErrNotObject
+	}
+	return m, entry.Version, nil
+}
+
+// deleteChunks deletes the first n chunks of object upload id, failure is only logged.
+func (conn *BlockConnection) deleteChunks(key []byte, id []byte, n int) {
+	for i := 0; i < n; i++ {
+		status, err := conn.Delete(&Record{Key: chunkKey(key, id, i), Sync: SyncWriteBack, Force: true})
+		if err == nil && status.Code != OK && status.Code != RemoteNotFound {
+			err = status
+		}
+		if err != nil {
+			klog.Warnf("Delete chunk[%d] of object [%s] fail : %s", i, key, err.Error())
+		}
+	}
+}
+
+// ObjectWriter writes large object as chunks, and the object manifest when closed.
+// Chunks are written with SyncWriteBack, and the manifest with SyncFlush so all chunks
+// are persistent once Close returns. Chunks of replaced object are deleted after the new
+// manifest written. If any write fails, or Abort is called, chunks already written are deleted
+// and the previous object of the key, if any, is untouched.
+type ObjectWriter struct {
+	conn *BlockConnection
+	key  []byte
+	m    objectManifest
+	buf  []byte
+	sent int // Number of chunk PUT requests sent, including the failed one
+	err  error
+}
+
+// CreateObject returns ObjectWriter to write object of key.
+func (conn *BlockConnection) CreateObject(key []byte, op ObjectOptions) (*ObjectWriter, error) {
+	if len(key)+13 > conn.nbc.service.maxKeySize() {
+		return nil, errors.New("Object key too long")
+	}
+	max := conn.nbc.service.maxValueSize()
+	if op.ChunkSize == 0 {
+		op.ChunkSize = max
+	}
+	if op.ChunkSize < 0 || op.ChunkSize > max {
+		return nil, fmt.Errorf("Chunk size should with range (1 -- %d)", max)
+	}
+	if op.Algo == 0 {
+		op.Algo = AlgorithmSHA1
+	}
+	if _, err := ComputeTag(op.Algo, nil); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	w := &ObjectWriter{
+		conn: conn,
+		key:  append([]byte(nil), key...),
+		m: objectManifest{
+			Format:    objectFormat,
+			ID:        id,
+			ChunkSize: int64(op.ChunkSize),
+			Algo:      op.Algo,
+			Tags:      [][]byte{},
+		},
+		buf: make([]byte, 0, op.ChunkSize),
+	}
+	return w, nil
+}
+
+// PutObject writes all data from r as object of key.
+// It returns the number of bytes written and the first error encountered.
+func (conn *BlockConnection) PutObject(key []byte, r io.Reader, op ObjectOptions) (int64, error) {
+	w, err := conn.CreateObject(key, op)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, r)
+	if err != nil {
+		w.Abort()
+		return n, err
+	}
+	return n, w.Close()
+}
+
+// fail stops the writer with err and deletes chunks already written.
+func (w *ObjectWriter) fail(err error) error {
+	w.err = err
+	w.conn.deleteChunks(w.key, w.m.ID, w.sent)
+	return err
+}
+
+// flush writes buffered data as next chunk.
+func (w *ObjectWriter) flush() error {
+	index := w.sent
+	tag, _ := ComputeTag(w.m.Algo, w.buf)
+	w.sent++
+	status, err := w.conn.Put(&Record{
+		Key:   chunkKey(w.key, w.m.ID, index),
+		Value: w.buf,
+		Tag:   tag,
+		Algo:  w.m.Algo,
+		Sync:  SyncWriteBack,
+		Force: true,
+	})
+	if err == nil && status.Code != OK {
+		err = status
+	}
+	if err != nil {
+		klog.Errorf("Write chunk[%d] of object [%s] fail : %s", index, w.key, err.Error())
+		return w.fail(err)
+	}
+	w.m.Tags = append(w.m.Tags, tag)
+	w.m.Size += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Write writes p to object, a chunk is written to device each time ChunkSize bytes buffered.
+func (w *ObjectWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		c := cap(w.buf) - len(w.buf)
+		if c > len(p) {
+			c = len(p)
+		}
+		w.buf = append(w.buf, p[:c]...)
+		p = p[c:]
+		n += c
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close writes the last chunk and the object manifest, then deletes chunks of replaced object.
+// If the object is replaced by another writer meanwhile, Close fails with VersionMismatchError
+// and chunks of this writer are deleted.
+func (w *ObjectWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+
+	value, err := json.Marshal(&w.m)
+	if err != nil {
+		return w.fail(err)
+	}
+	if len(value) > w.conn.nbc.service.maxValueSize() {
+		return w.fail(errors.New("Object too large, manifest exceeds MaxValueSize"))
+	}
+
+	// Manifest is replaced only if not changed since read, or created only if not exist,
+	// so of concurrent writers only one wins and the others delete their own chunks.
+	old, version, err := w.conn.manifest(w.key)
+	if s, ok := err.(Status); ok && s.Code == RemoteNotFound {
+		err = nil
+	}
+	if err != nil && err != ErrNotObject {
+		return w.fail(err)
+	}
+
+	tag, _ := ComputeTag(w.m.Algo, value)
+	status, err := w.conn.Put(&Record{Key: w.key, Value: value, Tag: tag, Algo: w.m.Algo, Sync: SyncFlush,
+		Version: version, NewVersion: w.m.ID})
+	if err == nil && status.Code != OK {
+		err = status
+	}
+	if err != nil {
+		klog.Errorf("Write manifest of object [%s] fail : %s", w.key, err.Error())
+		return w.fail(err)
+	}
+	w.err = errors.New("Object writer closed")
+
+	if old != nil {
+		w.conn.deleteChunks(w.key, old.ID, len(old.Tags))
+	}
+	return nil
+}
+
+// Abort stops writing object and deletes chunks already written.
+func (w *ObjectWriter) Abort() {
+	if w.err == nil {
+		w.fail(errors.New("Object writer aborted"))
+	}
+}
+
+// DeleteObject deletes object manifest of key, then all its chunks.
+func (conn *BlockConnection) DeleteObject(key []byte) error {
+	m, _, err := conn.manifest(key)
+	if err != nil {
+		return err
+	}
+	status, err := conn.Delete(&Record{Key: key, Sync: SyncWriteBack, Force: true})
+	if err == nil && status.Code != OK {
+		err = status
+	}
+	if err != nil {
+		return err
+	}
+	conn.deleteChunks(key, m.ID, len(m.Tags))
+	return nil
+}
+
+// ObjectReader reads large object written by ObjectWriter. Chunks are fetched lazily
+// when read, and verified against tags in object manifest.
+type ObjectReader struct {
+	conn  *BlockConnection
+	key   []byte
+	m     *objectManifest
+	off   int64
+	index int // Index of chunk cached, -1 if none
+	chunk []byte
+}
+
+// Open returns ObjectReader to read object of key.
+func (conn *BlockConnection) Open(key []byte) (*ObjectReader, error) {
+	m, _, err := conn.manifest(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectReader{conn: conn, key: append([]byte(nil), key...), m: m, index: -1}, nil
+}
+
+// Size returns size of object.
+func (r *ObjectReader) Size() int64 {
+	return r.m.Size
+}
+
+// load reads chunk index and verifies its tag.
+func (r *ObjectReader) load(index int) error {
+	entry, status, err := r.conn.Get(chunkKey(r.key, r.m.ID, index))
+	if err != nil {
+		return err
+	}
+	if status.Code != OK {
+		return status
+	}
+	if int64(len(entry.Value)) != r.m.chunkLen(index) {
+		return Status{Code: ClientIntegrityError, ErrorMsg: "Chunk size not match object manifest"}
+	}
+	if tag, _ := ComputeTag(r.m.Algo, entry.Value); !bytes.Equal(tag, r.m.Tags[index]) {
+		return Status{Code: ClientIntegrityError, ErrorMsg: "Chunk tag not match object manifest"}
+	}
+	r.index = index
+	r.chunk = entry.Value
+	return nil
+}
+
+// Read reads up to len(p) bytes from current offset, within one chunk.
+func (r *ObjectReader) Read(p []byte) (int, error) {
+	if r.off >= r.m.Size {
+		return 0, io.EOF
+	}
+	index := int(r.off / r.m.ChunkSize)
+	if index != r.index {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk[r.off-int64(index)*r.m.ChunkSize:])
+	r.off += int64(n)
+	return n, nil
+}
+
+// Seek sets the offset for the next Read, interpreted according to whence.
+func (r *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.m.Size
+	default:
+		return r.off, errors.New("Seek with invalid whence")
+	}
+	if offset < 0 {
+		return r.off, errors.New("Seek to negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
@@ -0,0 +1,191 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+// failReader returns data, then err.
+type failReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func countKeys(t *testing.T, conn *BlockConnection, prefix []byte) int {
+	n := 0
+	it := conn.KeysPrefix(prefix)
+	for it.Next() {
+		n++
+	}
+	if it.Err() != nil {
+		t.Fatal("KeysPrefix Failure", it.Err())
+	}
+	return n
+}
+
+func TestBlockObject(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	key := []byte("object")
+	data := make([]byte, 2500)
+	rand.Read(data)
+
+	n, err := conn.PutObject(key, bytes.NewReader(data), ObjectOptions{ChunkSize: 1000})
+	if err != nil || n != int64(len(data)) {
+		t.Fatal("PutObject Failure", err, n)
+	}
+	if cnt := countKeys(t, conn, key); cnt != 4 {
+		t.Fatal("PutObject expect manifest and 3 chunks, actual keys", cnt)
+	}
+
+	r, err := conn.Open(key)
+	if err != nil || r.Size() != int64(len(data)) {
+		t.Fatal("Open Failure", err)
+	}
+	read, err := ioutil.ReadAll(r)
+	if err != nil || !bytes.Equal(read, data) {
+		t.Fatal("Read object Failure", err, len(read))
+	}
+
+	buf := make([]byte, 100)
+	if off, err := r.Seek(1950, io.SeekStart); err != nil || off != 1950 {
+		t.Fatal("Seek Failure", err, off)
+	}
+	if _, err := io.ReadFull(r, buf); err != nil || !bytes.Equal(buf, data[1950:2050]) {
+		t.Fatal("Read across chunks Failure", err)
+	}
+	if off, err := r.Seek(-10, io.SeekEnd); err != nil || off != 2490 {
+		t.Fatal("Seek from end Failure", err, off)
+	}
+	if read, err = ioutil.ReadAll(r); err != nil || !bytes.Equal(read, data[2490:]) {
+		t.Fatal("Read after seek Failure", err)
+	}
+
+	// Failed upload is rolled back, previous object untouched
+	_, err = conn.PutObject(key, &failReader{data: data[:1500], err: errors.New("read fail")}, ObjectOptions{ChunkSize: 1000})
+	if err == nil {
+		t.Fatal("PutObject with failed reader expect error")
+	}
+	if cnt := countKeys(t, conn, key); cnt != 4 {
+		t.Fatal("Failed PutObject not rolled back, actual keys", cnt)
+	}
+
+	// Replace object, chunks of previous object deleted
+	if _, err = conn.PutObject(key, bytes.NewReader(data[:500]), ObjectOptions{}); err != nil {
+		t.Fatal("Replace object Failure", err)
+	}
+	if cnt := countKeys(t, conn, key); cnt != 2 {
+		t.Fatal("Replace object expect manifest and 1 chunk, actual keys", cnt)
+	}
+	r, err = conn.Open(key)
+	if err != nil {
+		t.Fatal("Open Failure", err)
+	}
+	if read, err = ioutil.ReadAll(r); err != nil || !bytes.Equal(read, data[:500]) {
+		t.Fatal("Read replaced object Failure", err)
+	}
+
+	// Corrupted chunk detected
+	status, err := conn.Put(&Record{Key: chunkKey(key, r.m.ID, 0), Value: data[500:1000], Sync: SyncWriteBack, Force: true})
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+	r.Seek(0, io.SeekStart)
+	r.index = -1
+	if _, err = ioutil.ReadAll(r); err == nil || err.(Status).Code != ClientIntegrityError {
+		t.Fatal("Read corrupted chunk expect integrity error", err)
+	}
+
+	if err = conn.DeleteObject(key); err != nil {
+		t.Fatal("DeleteObject Failure", err)
+	}
+	if cnt := countKeys(t, conn, key); cnt != 0 {
+		t.Fatal("DeleteObject left keys", cnt)
+	}
+	if _, err = conn.Open(key); err == nil || err.(Status).Code != RemoteNotFound {
+		t.Fatal("Open deleted object expect not found", err)
+	}
+
+	// Plain value is not object
+	conn.Put(&Record{Key: key, Value: []byte("value"), Sync: SyncWriteBack, Force: true})
+	if _, err = conn.Open(key); err != ErrNotObject {
+		t.Fatal("Open plain value expect ErrNotObject", err)
+	}
+	if _, err = conn.PutObject(key, bytes.NewReader(data), ObjectOptions{ChunkSize: 1000}); err != nil {
+		t.Fatal("PutObject replace plain value Failure", err)
+	}
+	if err = conn.DeleteObject(key); err != nil {
+		t.Fatal("DeleteObject Failure", err)
+	}
+}
+
+func TestBlockObjectConcurrentClose(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	key := []byte("concurrent-object")
+	const writers = 8
+	errs := make(chan error, writers)
+	for k := 0; k < writers; k++ {
+		w, err := conn.CreateObject(key, ObjectOptions{ChunkSize: 100})
+		if err != nil {
+			t.Fatal("CreateObject Failure", err)
+		}
+		if _, err = w.Write(bytes.Repeat([]byte{byte(k)}, 100)); err != nil {
+			t.Fatal("ObjectWriter Write Failure", err)
+		}
+		go func() {
+			errs <- w.Close()
+		}()
+	}
+
+	// Writer loses only by version mismatch, and leaves no chunks behind
+	for k := 0; k < writers; k++ {
+		if err := <-errs; err != nil && !IsVersionMismatch(err) {
+			t.Fatal("ObjectWriter Close Failure", err)
+		}
+	}
+	if cnt := countKeys(t, conn, key); cnt != 2 {
+		t.Fatal("Concurrent Close expect manifest and 1 chunk, actual keys", cnt)
+	}
+	if err = conn.DeleteObject(key); err != nil {
+		t.Fatal("DeleteObject Failure", err)
+	}
+}
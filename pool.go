@@ -0,0 +1,356 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPoolSize is default max connections in Pool,
+	// if kinetic device doesn't report MaxConnections limit.
+	DefaultPoolSize = 8
+	// DefaultPoolHealthCheckInterval is default idle time after which a pooled
+	// connection is checked by NoOp before handed out.
+	DefaultPoolHealthCheckInterval = 30 * time.Second
+)
+
+// ErrPoolClosed is returned when acquire connection from closed Pool.
+var ErrPoolClosed = errors.New("Connection pool closed")
+
+// PoolOptions specify connection pool options to kinetic device.
+type PoolOptions struct {
+	ClientOptions
+	Size                int   // Max connections in pool, 0 to use device MaxConnections limit
+	HealthCheckInterval int64 // Connection idle longer than this is checked by NoOp before handed out, in millisecond
+}
+
+type idleConn struct {
+	conn     *BlockConnection
+	lastUsed time.Time
+}
+
+// Pool maintains connections to one kinetic device, so operations can run in parallel
+// on different connections. Max connections is bounded by MaxConnections reported by device.
+// Pool has the same Get / Put / Delete surface as BlockConnection, each operation runs on
+// a connection acquired from pool.
+type Pool struct {
+	op            ClientOptions
+	size          int
+	checkInterval time.Duration
+	slots         chan struct{} // Each token is a connection handed out
+	mu            sync.Mutex
+	idle          []idleConn
+	closed        bool
+}
+
+// NewPool is helper function to create connection pool to device.
+// The first connection is established to get device limits.
+func NewPool(op PoolOptions) (*Pool, error) {
+	conn, err := NewBlockConnection(op.ClientOptions)
+	if err != nil {
+		klog.Error("Can't establish connection for pool")
+		return nil, err
+	}
+
+	size := op.Size
+	limits := conn.nbc.service.limits()
+	if limits != nil && limits.MaxConnections > 0 && (size <= 0 || size > int(limits.MaxConnections)) {
+		size = int(limits.MaxConnections)
+	}
+	if size <= 0 {
+		size = DefaultPoolSize
+	}
+
+	checkInterval := DefaultPoolHealthCheckInterval
+	if op.HealthCheckInterval > 0 {
+		checkInterval = time.Duration(op.HealthCheckInterval) * time.Millisecond
+	}
+
+	p := &Pool{
+		op:            op.ClientOptions,
+		size:          size,
+		checkInterval: checkInterval,
+		slots:         make(chan struct{}, size),
+		idle:          []idleConn{{conn: conn, lastUsed: time.Now()}},
+	}
+	klog.Debugf("Connection pool to %s:%d, size %d", op.Host, op.Port, size)
+
+	return p, nil
+}
+
+// Size returns max connections in pool.
+func (p *Pool) Size() int {
+	return p.size
+}
+
+// Acquire gets a connection from pool, it blocks if all connections are in use.
+// Connection must be returned to pool by Release.
+func (p *Pool) Acquire() (*BlockConnection, error) {
+	return p.AcquireContext(context.Background())
+}
+
+// AcquireContext is Acquire with ctx to cancel waiting for connection.
+func (p *Pool) AcquireContext(ctx context.Context) (*BlockConnection, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	conn, err := p.idleConn(ctx)
+	if err == nil && conn == nil {
+		conn, err = NewBlockConnection(p.op)
+	}
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// idleConn returns a healthy idle connection, or nil if no idle connection.
+// Broken connection is closed and evicted from pool.
+func (p *Pool) idleConn(ctx context.Context) (*BlockConnection, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		n := len(p.idle)
+		if n == 0 {
+			p.mu.Unlock()
+			return nil, nil
+		}
+		ic := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+
+		if broken(ic.conn) {
+			ic.conn.Close()
+			continue
+		}
+		if time.Since(ic.lastUsed) > p.checkInterval {
+			status, err := ic.conn.NoOpContext(ctx)
+			if err != nil || status.Code != OK {
+				klog.Warnf("Pooled connection to %s failed health check, %s", p.op.Host, status.String())
+				ic.conn.Close()
+				continue
+			}
+		}
+		return ic.conn, nil
+	}
+}
+
+// broken returns true if network service of conn has fatal error.
+func broken(conn *BlockConnection) bool {
+	return conn.nbc.service.fatalErr() != nil
+}
+
+// Release returns connection acquired by Acquire to pool.
+// Broken connection is closed instead.
+func (p *Pool) Release(conn *BlockConnection) {
+	p.mu.Lock()
+	if p.closed || broken(conn) {
+		p.mu.Unlock()
+		conn.Close()
+	} else {
+		p.idle = append(p.idle, idleConn{conn: conn, lastUsed: time.Now()})
+		p.mu.Unlock()
+	}
+	<-p.slots
+}
+
+// Close closes all idle connections in pool. Connections in use are closed when released.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, ic := range idle {
+		ic.conn.Close()
+	}
+}
+
+// poolError converts error from Acquire to Status.
+func poolError(err error) Status {
+	if err == ErrPoolClosed {
+		return Status{Code: ClientShutdown, ErrorMsg: err.Error()}
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return contextStatus(err)
+	}
+	return Status{Code: ClientIOError, ErrorMsg: err.Error()}
+}
+
+// NoOp does nothing but wait for drive to return response, on a pooled connection.
+func (p *Pool) NoOp() (Status, error) {
+	return p.NoOpContext(context.Background())
+}
+
+// NoOpContext is NoOp with ctx to cancel the operation and set its deadline.
+func (p *Pool) NoOpContext(ctx context.Context) (Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.NoOpContext(ctx)
+}
+
+// Get gets the object from kinetic drive with key, on a pooled connection.
+func (p *Pool) Get(key []byte) (*Record, Status, error) {
+	return p.GetContext(context.Background(), key)
+}
+
+// GetContext is Get with ctx to cancel the operation and set its deadline.
+func (p *Pool) GetContext(ctx context.Context, key []byte) (*Record, Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return nil, poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.GetContext(ctx, key)
+}
+
+// GetNext gets the next object with key after the passed in key, on a pooled connection.
+func (p *Pool) GetNext(key []byte) (*Record, Status, error) {
+	return p.GetNextContext(context.Background(), key)
+}
+
+// GetNextContext is GetNext with ctx to cancel the operation and set its deadline.
+func (p *Pool) GetNextContext(ctx context.Context, key []byte) (*Record, Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return nil, poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.GetNextContext(ctx, key)
+}
+
+// GetPrevious gets the previous object with key before the passed in key, on a pooled connection.
+func (p *Pool) GetPrevious(key []byte) (*Record, Status, error) {
+	return p.GetPreviousContext(context.Background(), key)
+}
+
+// GetPreviousContext is GetPrevious with ctx to cancel the operation and set its deadline.
+func (p *Pool) GetPreviousContext(ctx context.Context, key []byte) (*Record, Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return nil, poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.GetPreviousContext(ctx, key)
+}
+
+// GetKeyRange gets list of objects' keys, which meet the criteria defined by KeyRange, on a pooled connection.
+func (p *Pool) GetKeyRange(r *KeyRange) ([][]byte, Status, error) {
+	return p.GetKeyRangeContext(context.Background(), r)
+}
+
+// GetKeyRangeContext is GetKeyRange with ctx to cancel the operation and set its deadline.
+func (p *Pool) GetKeyRangeContext(ctx context.Context, r *KeyRange) ([][]byte, Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return nil, poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.GetKeyRangeContext(ctx, r)
+}
+
+// GetVersion gets object DB version information, on a pooled connection.
+func (p *Pool) GetVersion(key []byte) ([]byte, Status, error) {
+	return p.GetVersionContext(context.Background(), key)
+}
+
+// GetVersionContext is GetVersion with ctx to cancel the operation and set its deadline.
+func (p *Pool) GetVersionContext(ctx context.Context, key []byte) ([]byte, Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return nil, poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.GetVersionContext(ctx, key)
+}
+
+// Flush requests kinetic device to write all cached data to persistent media, on a pooled connection.
+func (p *Pool) Flush() (Status, error) {
+	return p.FlushContext(context.Background())
+}
+
+// FlushContext is Flush with ctx to cancel the operation and set its deadline.
+func (p *Pool) FlushContext(ctx context.Context) (Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.FlushContext(ctx)
+}
+
+// Delete deletes object from kinetic device, on a pooled connection.
+func (p *Pool) Delete(entry *Record) (Status, error) {
+	return p.DeleteContext(context.Background(), entry)
+}
+
+// DeleteContext is Delete with ctx to cancel the operation and set its deadline.
+func (p *Pool) DeleteContext(ctx context.Context, entry *Record) (Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.DeleteContext(ctx, entry)
+}
+
+// Put store object to kinetic device, on a pooled connection.
+func (p *Pool) Put(entry *Record) (Status, error) {
+	return p.PutContext(context.Background(), entry)
+}
+
+// PutContext is Put with ctx to cancel the operation and set its deadline.
+func (p *Pool) PutContext(ctx context.Context, entry *Record) (Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.PutContext(ctx, entry)
+}
+
+// GetLog gets kinetic device Log information, on a pooled connection.
+func (p *Pool) GetLog(logs []LogType) (*Log, Status, error) {
+	return p.GetLogContext(context.Background(), logs)
+}
+
+// GetLogContext is GetLog with ctx to cancel the operation and set its deadline.
+func (p *Pool) GetLogContext(ctx context.Context, logs []LogType) (*Log, Status, error) {
+	conn, err := p.AcquireContext(ctx)
+	if err != nil {
+		return nil, poolError(err), err
+	}
+	defer p.Release(conn)
+	return conn.GetLogContext(ctx, logs)
+}
@@ -0,0 +1,122 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Kinetic/kinetic-go/simulator"
+	proto "github.com/golang/protobuf/proto"
+)
+
+func TestPool(t *testing.T) {
+	p, err := NewPool(PoolOptions{ClientOptions: option, Size: 4})
+	if err != nil {
+		t.Fatal("Pool create Failure", err)
+	}
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := Record{
+				Key:   []byte(fmt.Sprintf("pool%03d", i)),
+				Value: []byte(fmt.Sprintf("pool value %03d", i)),
+				Sync:  SyncWriteBack,
+				Algo:  AlgorithmSHA1,
+				Force: true,
+			}
+			if status, err := p.Put(&entry); err != nil || status.Code != OK {
+				errs <- fmt.Errorf("Pool Put Failure %v %s", err, status.String())
+				return
+			}
+			record, status, err := p.Get(entry.Key)
+			if err != nil || status.Code != OK || !bytes.Equal(record.Value, entry.Value) {
+				errs <- fmt.Errorf("Pool Get Failure %v %s", err, status.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	p.mu.Lock()
+	n := len(p.idle)
+	p.mu.Unlock()
+	if n > 4 {
+		t.Fatal("Pool has more connections than its size", n)
+	}
+}
+
+func TestPoolEvictBroken(t *testing.T) {
+	p, err := NewPool(PoolOptions{ClientOptions: option, Size: 1})
+	if err != nil {
+		t.Fatal("Pool create Failure", err)
+	}
+	defer p.Close()
+
+	conn, err := p.Acquire()
+	if err != nil {
+		t.Fatal("Pool Acquire Failure", err)
+	}
+	conn.Close()
+	p.Release(conn)
+
+	conn2, err := p.Acquire()
+	if err != nil {
+		t.Fatal("Pool Acquire Failure", err)
+	}
+	if conn2 == conn {
+		t.Fatal("Pool handed out broken connection")
+	}
+	p.Release(conn2)
+
+	p.Close()
+	if _, err = p.NoOp(); err != ErrPoolClosed {
+		t.Fatal("Pool NoOp expected ErrPoolClosed", err)
+	}
+}
+
+func TestPoolDeviceLimit(t *testing.T) {
+	limits := simulator.DefaultLimits()
+	limits.MaxConnections = proto.Uint32(2)
+	sim, err := simulator.New(simulator.Options{Host: option.Host, Limits: limits})
+	if err != nil {
+		t.Fatal("Simulator start failure", err)
+	}
+	defer sim.Close()
+
+	op := option
+	op.Port = sim.Port()
+	p, err := NewPool(PoolOptions{ClientOptions: op, Size: 10})
+	if err != nil {
+		t.Fatal("Pool create Failure", err)
+	}
+	defer p.Close()
+	if p.Size() != 2 {
+		t.Fatal("Pool size not bounded by device MaxConnections", p.Size())
+	}
+}
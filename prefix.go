@@ -0,0 +1,134 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"context"
+)
+
+// DefaultMaxKeySize is max key size of kinetic device, if device doesn't report MaxKeySize limit.
+const DefaultMaxKeySize = 4096
+
+// prefixEnd returns the smallest key greater than all keys with prefix. It returns nil if no
+// such key exists, which is when prefix is empty or all 0xFF.
+func prefixEnd(prefix []byte) []byte {
+	for k := len(prefix) - 1; k >= 0; k-- {
+		if prefix[k] != 0xFF {
+			end := make([]byte, k+1)
+			copy(end, prefix)
+			end[k]++
+			return end
+		}
+	}
+	return nil
+}
+
+// prefixRange returns KeyRange of all keys with prefix. If prefix is empty or all 0xFF, the upper
+// bound is the greatest key of maxKeySize, inclusive.
+func prefixRange(prefix []byte, maxKeySize int) *KeyRange {
+	r := &KeyRange{
+		StartKey:          append([]byte{}, prefix...),
+		StartKeyInclusive: true,
+	}
+	if end := prefixEnd(prefix); end != nil {
+		r.EndKey = end
+	} else {
+		if len(prefix) > maxKeySize {
+			maxKeySize = len(prefix)
+		}
+		r.EndKey = bytes.Repeat([]byte{0xFF}, maxKeySize)
+		r.EndKeyInclusive = true
+	}
+	return r
+}
+
+// PrefixRange returns KeyRange of all keys with prefix, Max is not set.
+// If prefix is empty or all 0xFF, the upper bound is the greatest key of DefaultMaxKeySize.
+func PrefixRange(prefix []byte) *KeyRange {
+	return prefixRange(prefix, DefaultMaxKeySize)
+}
+
+// PrefixMediaOperation returns MediaOperation of all keys with prefix.
+func PrefixMediaOperation(prefix []byte) *MediaOperation {
+	return prefixMediaOperation(prefix, DefaultMaxKeySize)
+}
+
+func prefixMediaOperation(prefix []byte, maxKeySize int) *MediaOperation {
+	r := prefixRange(prefix, maxKeySize)
+	return &MediaOperation{
+		StartKey:          r.StartKey,
+		EndKey:            r.EndKey,
+		StartKeyInclusive: r.StartKeyInclusive,
+		EndKeyInclusive:   r.EndKeyInclusive,
+	}
+}
+
+// prefixRange returns KeyRange of all keys with prefix, bounded by device MaxKeySize.
+// If max is 0, device MaxKeyRangeCount is used.
+func (conn *NonBlockConnection) prefixRange(prefix []byte, max int32) *KeyRange {
+	r := prefixRange(prefix, conn.service.maxKeySize())
+	r.Max = max
+	if r.Max <= 0 {
+		r.Max = conn.service.maxKeyRangeCount()
+	}
+	return r
+}
+
+// GetKeyRangePrefix gets list of up to max objects' keys with prefix.
+// If max is 0, device MaxKeyRangeCount is used.
+func (conn *NonBlockConnection) GetKeyRangePrefix(prefix []byte, max int32, h *ResponseHandler) error {
+	return conn.GetKeyRangeContext(context.Background(), conn.prefixRange(prefix, max), h)
+}
+
+// KeysPrefix returns KeyIterator of all keys with prefix.
+func (conn *NonBlockConnection) KeysPrefix(prefix []byte, prefetch bool) *KeyIterator {
+	return newKeyIterator(conn, conn.prefixRange(prefix, 0), prefetch)
+}
+
+// MediaScanPrefix performs MediaScan on all keys with prefix.
+func (conn *NonBlockConnection) MediaScanPrefix(prefix []byte, pri Priority, h *ResponseHandler) error {
+	return conn.MediaScan(prefixMediaOperation(prefix, conn.service.maxKeySize()), pri, h)
+}
+
+// MediaOptimizePrefix performs MediaOptimize on all keys with prefix.
+func (conn *NonBlockConnection) MediaOptimizePrefix(prefix []byte, pri Priority, h *ResponseHandler) error {
+	return conn.MediaOptimize(prefixMediaOperation(prefix, conn.service.maxKeySize()), pri, h)
+}
+
+// GetKeyRangePrefix gets list of up to max objects' keys with prefix.
+// If max is 0, device MaxKeyRangeCount is used.
+// On success, list of objects's keys returned, and Status.Code = OK
+func (conn *BlockConnection) GetKeyRangePrefix(prefix []byte, max int32) ([][]byte, Status, error) {
+	return conn.GetKeyRange(conn.nbc.prefixRange(prefix, max))
+}
+
+// KeysPrefix returns KeyIterator of all keys with prefix.
+func (conn *BlockConnection) KeysPrefix(prefix []byte) *KeyIterator {
+	return newKeyIterator(conn.nbc, conn.nbc.prefixRange(prefix, 0), false)
+}
+
+// MediaScanPrefix performs MediaScan on all keys with prefix.
+func (conn *BlockConnection) MediaScanPrefix(prefix []byte, pri Priority) (Status, error) {
+	return conn.MediaScan(prefixMediaOperation(prefix, conn.nbc.service.maxKeySize()), pri)
+}
+
+// MediaOptimizePrefix performs MediaOptimize on all keys with prefix.
+func (conn *BlockConnection) MediaOptimizePrefix(prefix []byte, pri Priority) (Status, error) {
+	return conn.MediaOptimize(prefixMediaOperation(prefix, conn.nbc.service.maxKeySize()), pri)
+}
@@ -0,0 +1,100 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrefixEnd(t *testing.T) {
+	cases := []struct {
+		prefix []byte
+		end    []byte
+	}{
+		{[]byte("abc"), []byte("abd")},
+		{[]byte{'a', 0xFF}, []byte{'b'}},
+		{[]byte{'a', 0xFE, 0xFF, 0xFF}, []byte{'a', 0xFF}},
+		{[]byte{0xFF, 0xFF}, nil},
+		{[]byte{}, nil},
+	}
+	for _, c := range cases {
+		if end := prefixEnd(c.prefix); !bytes.Equal(end, c.end) {
+			t.Fatalf("prefixEnd(%x) = %x, expect %x", c.prefix, end, c.end)
+		}
+	}
+
+	r := PrefixRange([]byte{0xFF})
+	if !r.EndKeyInclusive || len(r.EndKey) != DefaultMaxKeySize || !r.StartKeyInclusive {
+		t.Fatal("PrefixRange for all 0xFF prefix Failure", r.EndKeyInclusive, len(r.EndKey))
+	}
+	r = PrefixRange([]byte("abc"))
+	if r.EndKeyInclusive || !bytes.Equal(r.EndKey, []byte("abd")) {
+		t.Fatal("PrefixRange Failure", string(r.EndKey))
+	}
+}
+
+func TestBlockKeysPrefix(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	keys := [][]byte{
+		[]byte("pfw"),
+		[]byte("pfx"),
+		{'p', 'f', 'x', 0xFF},
+		{'p', 'f', 'x', 0xFF, 0x01},
+		[]byte("pfy"),
+		{0xFF, 0xFE},
+		{0xFF, 0xFF},
+		{0xFF, 0xFF, 0x00},
+	}
+	for _, key := range keys {
+		status, err := conn.Put(&Record{Key: key, Value: key, Sync: SyncWriteBack, Force: true})
+		if err != nil || status.Code != OK {
+			t.Fatal("Blocking Put Failure", err, status.String())
+		}
+	}
+
+	check := func(it *KeyIterator, expect [][]byte) {
+		n := 0
+		for it.Next() {
+			if n >= len(expect) || !bytes.Equal(it.Key(), expect[n]) {
+				t.Fatalf("KeysPrefix unexpected key %x", it.Key())
+			}
+			n++
+		}
+		if it.Err() != nil || n != len(expect) {
+			t.Fatal("KeysPrefix Failure", it.Err(), n)
+		}
+	}
+	check(conn.KeysPrefix([]byte("pfx")), keys[1:4])
+	check(conn.KeysPrefix([]byte{0xFF, 0xFF}), keys[6:])
+
+	found, status, err := conn.GetKeyRangePrefix([]byte("pfx"), 2)
+	if err != nil || status.Code != OK || len(found) != 2 || !bytes.Equal(found[0], keys[1]) {
+		t.Fatal("Blocking GetKeyRangePrefix Failure", err, status.String(), len(found))
+	}
+
+	status, err = conn.MediaScanPrefix([]byte("pfx"), PriorityNormal)
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking MediaScanPrefix Failure", err, status.String())
+	}
+}
@@ -0,0 +1,323 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+const (
+	// DefaultReconnectInterval is default wait time between redial attempts.
+	DefaultReconnectInterval = 1 * time.Second
+	// DefaultReconnectMaxInterval is default max wait time between redial attempts,
+	// wait time is doubled after each failed attempt.
+	DefaultReconnectMaxInterval = 30 * time.Second
+)
+
+// RetryPolicy defines how requests in flight are handled when connection lost.
+type RetryPolicy int32
+
+// RetryPolicy for requests in flight
+const (
+	// RetryNone fails all requests in flight.
+	RetryNone RetryPolicy = iota
+	// RetryIdempotent resubmits requests safe to repeat after reconnected, eg GET, GETKEYRANGE,
+	// GETLOG, NOOP, FLUSH. Other requests fail, eg PUT, DELETE.
+	RetryIdempotent RetryPolicy = iota
+)
+
+var strRetryPolicy = map[RetryPolicy]string{
+	RetryNone:       "RETRY_NONE",
+	RetryIdempotent: "RETRY_IDEMPOTENT",
+}
+
+func (p RetryPolicy) String() string {
+	str, ok := strRetryPolicy[p]
+	if ok {
+		return str
+	}
+	return "Unknown RetryPolicy"
+}
+
+// ReconnectOptions specify how connection reconnects to kinetic device when connection lost.
+type ReconnectOptions struct {
+	MaxAttempts int                   // Max redial attempts, 0 to retry until connection closed
+	Interval    int64                 // Wait time between redial attempts in millisecond, doubled after each failure
+	MaxInterval int64                 // Max wait time between redial attempts in millisecond
+	Retry       RetryPolicy           // How requests in flight are handled
+	Events      chan<- ReconnectEvent // Receive reconnect events, events are dropped if channel is full
+}
+
+// ReconnectEventType defines the reconnect progress.
+type ReconnectEventType int32
+
+// ReconnectEventType for each reconnect progress
+const (
+	ReconnectStarted       ReconnectEventType = iota // Connection lost, reconnect started
+	ReconnectAttemptFailed ReconnectEventType = iota // Redial attempt failed
+	ReconnectSucceeded     ReconnectEventType = iota // Reconnected and handshake done
+	ReconnectGaveUp        ReconnectEventType = iota // Reconnect failed, connection is not usable
+)
+
+var strReconnectEventType = map[ReconnectEventType]string{
+	ReconnectStarted:       "RECONNECT_STARTED",
+	ReconnectAttemptFailed: "RECONNECT_ATTEMPT_FAILED",
+	ReconnectSucceeded:     "RECONNECT_SUCCEEDED",
+	ReconnectGaveUp:        "RECONNECT_GAVE_UP",
+}
+
+func (t ReconnectEventType) String() string {
+	str, ok := strReconnectEventType[t]
+	if ok {
+		return str
+	}
+	return "Unknown ReconnectEventType"
+}
+
+// ReconnectEvent is sent to ReconnectOptions.Events during reconnect.
+type ReconnectEvent struct {
+	Type    ReconnectEventType
+	Attempt int   // Redial attempt number, start from 1
+	Err     error // Error caused connection lost or redial attempt failed
+}
+
+// Requests safe to resubmit after reconnected
+var idempotentMessage = map[kproto.Command_MessageType]bool{
+	kproto.Command_GET:          true,
+	kproto.Command_GETNEXT:      true,
+	kproto.Command_GETPREVIOUS:  true,
+	kproto.Command_GETKEYRANGE:  true,
+	kproto.Command_GETVERSION:   true,
+	kproto.Command_GETLOG:       true,
+	kproto.Command_NOOP:         true,
+	kproto.Command_FLUSHALLDATA: true,
+}
+
+// retryable returns true if request cmd will be resubmitted after reconnected.
+func (ns *networkService) retryable(cmd *kproto.Command) bool {
+	return ns.reconnect != nil && ns.reconnect.Retry == RetryIdempotent &&
+		idempotentMessage[cmd.GetHeader().GetMessageType()]
+}
+
+func (ns *networkService) emit(e ReconnectEvent) {
+	if ns.reconnect.Events == nil {
+		return
+	}
+	select {
+	case ns.reconnect.Events <- e:
+	default:
+	}
+}
+
+// startReconnect starts reconnect in background for network I/O error err.
+// Returns false if reconnect not enabled, or network service already failed or closed.
+func (ns *networkService) startReconnect(err error) bool {
+	if ns.reconnect == nil {
+		return false
+	}
+
+	ns.stateMu.Lock()
+	defer ns.stateMu.Unlock()
+	if ns.fatal {
+		return false
+	}
+	if ns.reconnecting == nil {
+		ns.reconnecting = make(chan struct{})
+		go ns.redial(err)
+	}
+	return true
+}
+
+// waitReconnect waits for reconnect in progress finished, returns true if reconnected.
+func (ns *networkService) waitReconnect() bool {
+	ns.stateMu.Lock()
+	ch := ns.reconnecting
+	ns.stateMu.Unlock()
+	if ch == nil {
+		return false
+	}
+
+	<-ch
+	return ns.fatalErr() == nil
+}
+
+// waitReady waits up to timeout for reconnect in progress before submit new request.
+// Returns error if network service has fatal error, or still reconnecting after timeout.
+func (ns *networkService) waitReady(timeout time.Duration) error {
+	ns.stateMu.Lock()
+	ch := ns.reconnecting
+	ns.stateMu.Unlock()
+	if ch != nil {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		select {
+		case <-ch:
+		case <-t.C:
+			return errors.New("Can't submit, network service is reconnecting to " + ns.option.Host)
+		}
+	}
+
+	if err := ns.fatalErr(); err != nil {
+		return errors.New("Can't submit, network service has fatal error: " + err.Error())
+	}
+	return nil
+}
+
+// redial closes the broken connection, redials kinetic device and redo handshake, which refreshes
+// connection ID, cluster version and device information. After reconnected, requests in flight are
+// resubmitted or failed according to ReconnectOptions.Retry.
+func (ns *networkService) redial(cause error) {
+	op := ns.reconnect
+	klog.Warnf("Connection to %s lost, reconnecting: %s", ns.option.Host, cause)
+	ns.emit(ReconnectEvent{Type: ReconnectStarted, Err: cause})
+
+	// Unblock receive or send on broken connection
+	ns.stateMu.Lock()
+	ns.conn.Close()
+	ns.stateMu.Unlock()
+
+	ns.rxMu.Lock()
+	ns.txMu.Lock()
+
+	ns.mapMu.Lock()
+	pending := make([]*ResponseHandler, 0, len(ns.hmap))
+	for _, h := range ns.hmap {
+		pending = append(pending, h)
+	}
+	ns.hmap = make(map[int64]*ResponseHandler)
+	ns.mapMu.Unlock()
+	// Resubmit in original order
+	sort.Sort(handlersBySeq(pending))
+
+	interval := DefaultReconnectInterval
+	if op.Interval > 0 {
+		interval = time.Duration(op.Interval) * time.Millisecond
+	}
+	maxInterval := DefaultReconnectMaxInterval
+	if op.MaxInterval > 0 {
+		maxInterval = time.Duration(op.MaxInterval) * time.Millisecond
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = ns.redialOnce(); err == nil {
+			break
+		}
+		klog.Warnf("Reconnect to %s attempt %d failed: %s", ns.option.Host, attempt, err)
+		ns.emit(ReconnectEvent{Type: ReconnectAttemptFailed, Attempt: attempt, Err: err})
+
+		if op.MaxAttempts > 0 && attempt >= op.MaxAttempts {
+			break
+		}
+		if !ns.sleep(interval) {
+			// Connection closed, stop retry
+			break
+		}
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+
+	ns.txMu.Unlock()
+	ns.rxMu.Unlock()
+
+	ns.stateMu.Lock()
+	done := ns.reconnecting
+	ns.reconnecting = nil
+	ns.stateMu.Unlock()
+
+	if err != nil {
+		klog.Errorf("Reconnect to %s failed: %s", ns.option.Host, err)
+		ns.setFatal(err)
+		close(done)
+		s := Status{Code: ClientIOError, ErrorMsg: "Reconnect failed, " + err.Error()}
+		for _, h := range pending {
+			h.fail(s)
+		}
+		// Requests submitted during reconnect
+		ns.clientError(s, nil)
+		ns.emit(ReconnectEvent{Type: ReconnectGaveUp, Err: err})
+		return
+	}
+
+	klog.Infof("Reconnected to %s", ns.option.Host)
+	close(done)
+	ns.emit(ReconnectEvent{Type: ReconnectSucceeded})
+
+	for _, h := range pending {
+		if h.msg == nil {
+			h.fail(Status{Code: ClientIOError, ErrorMsg: "Connection lost, " + cause.Error()})
+			continue
+		}
+		timeout := h.deadline.Sub(time.Now())
+		if timeout <= 0 {
+			h.fail(Status{Code: ClientRequestTimeout, ErrorMsg: "Request timeout during reconnect"})
+			continue
+		}
+		if err = ns.submitTimeout(h.msg, h.cmd, nil, h, timeout); err != nil {
+			h.fail(Status{Code: ClientIOError, ErrorMsg: "Resubmit after reconnect failed, " + err.Error()})
+		}
+	}
+}
+
+// sleep waits for d, returns false if network service closed before d passed.
+func (ns *networkService) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ns.closed:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// redialOnce makes new network connection and does handshake. Caller must hold rxMu and txMu.
+func (ns *networkService) redialOnce() error {
+	conn, err := ns.dial()
+	if err != nil {
+		return err
+	}
+
+	ns.stateMu.Lock()
+	if ns.fatal {
+		// Closed during dial
+		ns.stateMu.Unlock()
+		conn.Close()
+		return ns.fatalError
+	}
+	ns.conn = conn
+	ns.stateMu.Unlock()
+
+	if err = ns.handshake(); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+type handlersBySeq []*ResponseHandler
+
+func (s handlersBySeq) Len() int           { return len(s) }
+func (s handlersBySeq) Less(i, j int) bool { return s[i].seq < s[j].seq }
+func (s handlersBySeq) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
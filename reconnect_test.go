@@ -0,0 +1,195 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kinetic/kinetic-go/simulator"
+)
+
+func waitReconnectEvent(t *testing.T, events <-chan ReconnectEvent, et ReconnectEventType) {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type == et {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Timeout waiting for reconnect event", et.String())
+		}
+	}
+}
+
+// holdConn drops writes while hold is set, so requests stay in flight.
+type holdConn struct {
+	net.Conn
+	hold *int32
+}
+
+func (c *holdConn) Write(b []byte) (int, error) {
+	if atomic.LoadInt32(c.hold) != 0 {
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
+
+func TestReconnect(t *testing.T) {
+	sim, err := simulator.New(simulator.Options{Host: option.Host})
+	if err != nil {
+		t.Fatal("Simulator start failure", err)
+	}
+	port := sim.Port()
+
+	events := make(chan ReconnectEvent, 16)
+	op := option
+	op.Port = port
+	op.Reconnect = &ReconnectOptions{Interval: 20, Retry: RetryIdempotent, Events: events}
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	conn.nbc.service.stateMu.Lock()
+	connID := conn.nbc.service.connID
+	conn.nbc.service.stateMu.Unlock()
+
+	// Device reboot on same port
+	sim.Close()
+	waitReconnectEvent(t, events, ReconnectStarted)
+	sim, err = simulator.New(simulator.Options{Host: option.Host, Port: port})
+	if err != nil {
+		t.Fatal("Simulator restart failure", err)
+	}
+	defer sim.Close()
+	waitReconnectEvent(t, events, ReconnectSucceeded)
+
+	status, err := conn.NoOp()
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp after reconnect Failure", err, status.String())
+	}
+
+	conn.nbc.service.stateMu.Lock()
+	newConnID := conn.nbc.service.connID
+	conn.nbc.service.stateMu.Unlock()
+	if newConnID == connID {
+		t.Fatal("Connection ID not refreshed after reconnect")
+	}
+}
+
+func TestReconnectGaveUp(t *testing.T) {
+	sim, err := simulator.New(simulator.Options{Host: option.Host})
+	if err != nil {
+		t.Fatal("Simulator start failure", err)
+	}
+
+	events := make(chan ReconnectEvent, 16)
+	op := option
+	op.Port = sim.Port()
+	op.Reconnect = &ReconnectOptions{MaxAttempts: 2, Interval: 10, Events: events}
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	sim.Close()
+	waitReconnectEvent(t, events, ReconnectGaveUp)
+
+	if _, err = conn.NoOp(); err == nil {
+		t.Fatal("Blocking NoOp expected failure after reconnect gave up")
+	}
+}
+
+func TestReconnectInFlight(t *testing.T) {
+	sim, err := simulator.New(simulator.Options{Host: option.Host})
+	if err != nil {
+		t.Fatal("Simulator start failure", err)
+	}
+	port := sim.Port()
+
+	var hold int32
+	events := make(chan ReconnectEvent, 16)
+	op := option
+	op.Port = port
+	op.Reconnect = &ReconnectOptions{Interval: 20, Retry: RetryIdempotent, Events: events}
+	op.Dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &holdConn{Conn: c, hold: &hold}, nil
+	}
+	conn, err := NewNonBlockConnection(op)
+	if err != nil {
+		t.Fatal("NonBlocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	// Requests in flight when connection lost
+	atomic.StoreInt32(&hold, 1)
+	noopCallback := &GenericCallback{}
+	noop := NewResponseHandler(noopCallback)
+	if err = conn.NoOp(noop); err != nil {
+		t.Fatal("NonBlocking NoOp Failure", err)
+	}
+	putCallback := &GenericCallback{}
+	put := NewResponseHandler(putCallback)
+	if err = conn.Put(&Record{Key: []byte("reconnect"), Value: []byte("value"), Force: true}, put); err != nil {
+		t.Fatal("NonBlocking Put Failure", err)
+	}
+
+	// Device reboot with new cluster version and serial number
+	sim.Close()
+	waitReconnectEvent(t, events, ReconnectStarted)
+	atomic.StoreInt32(&hold, 0)
+	serial := []byte("reconnect-serial")
+	sim, err = simulator.New(simulator.Options{Host: option.Host, Port: port, ClusterVersion: 7, SerialNumber: serial})
+	if err != nil {
+		t.Fatal("Simulator restart failure", err)
+	}
+	defer sim.Close()
+	waitReconnectEvent(t, events, ReconnectSucceeded)
+
+	// Idempotent request resubmitted with refreshed cluster version
+	if err = conn.Listen(noop); err != nil || noopCallback.Status().Code != OK {
+		t.Fatal("NonBlocking NoOp resubmit after reconnect Failure", err, noopCallback.Status().String())
+	}
+	// Non idempotent request fails
+	if conn.Listen(put); putCallback.Status().Code != ClientIOError {
+		t.Fatal("NonBlocking Put in flight expected ClientIOError", putCallback.Status().String())
+	}
+
+	conn.service.stateMu.Lock()
+	clusterVersion := conn.service.clusterVersion
+	serialNumber := conn.service.device.Configuration.SerialNumber
+	conn.service.stateMu.Unlock()
+	if clusterVersion != 7 {
+		t.Fatal("Cluster version not refreshed after reconnect", clusterVersion)
+	}
+	if !bytes.Equal(serialNumber, serial) {
+		t.Fatal("Device log not refreshed after reconnect", string(serialNumber))
+	}
+}
@@ -0,0 +1,200 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ReplicationOptions specify how objects are replicated across drives in Cluster.
+type ReplicationOptions struct {
+	Replicas    int // Number of drives each object is stored on
+	WriteQuorum int // Acknowledgements required for Put / Delete to succeed
+	ReadQuorum  int // Responses required for Get
+}
+
+// QuorumError is returned when operation doesn't get enough acknowledgements from replicas.
+type QuorumError struct {
+	Acks     int              // Replicas acknowledged
+	Quorum   int              // Acknowledgements required
+	Failures map[string]error // Failure of each replica, by drive name
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("Quorum not reached, %d of %d replicas acknowledged, failures: %v", e.Acks, e.Quorum, e.Failures)
+}
+
+// Replicator stores each object on Replicas drives of Cluster. Replicas of key are the
+// distinct drives following key on hash ring, the first one is the drive owning key.
+// Conflicts between replicas are resolved by Record.Version, the greatest version by
+// bytes.Compare wins, so Version should increase in byte order, eg big endian counter.
+type Replicator struct {
+	c  *Cluster
+	op ReplicationOptions
+}
+
+// NewReplicator is helper function to build a Replicator on Cluster c.
+func NewReplicator(c *Cluster, op ReplicationOptions) (*Replicator, error) {
+	if op.Replicas <= 0 {
+		return nil, errors.New("Replicas must be greater than 0")
+	}
+	if op.WriteQuorum <= 0 || op.WriteQuorum > op.Replicas {
+		return nil, errors.New("WriteQuorum must be between 1 and Replicas")
+	}
+	if op.ReadQuorum <= 0 || op.ReadQuorum > op.Replicas {
+		return nil, errors.New("ReadQuorum must be between 1 and Replicas")
+	}
+	if drives := len(c.Drives()); op.WriteQuorum > drives || op.ReadQuorum > drives {
+		return nil, fmt.Errorf("Quorum exceeds number of drives %d in cluster", drives)
+	}
+	return &Replicator{c: c, op: op}, nil
+}
+
+// Replicas returns names of drives key is stored on.
+func (r *Replicator) Replicas(key []byte) []string {
+	names, _ := r.c.replicas(key, r.op.Replicas)
+	return names
+}
+
+type replicaResult struct {
+	drive  string
+	record *Record
+	status Status
+	err    error
+}
+
+// fanout runs op on all replicas of key, and calls collect with each result and number of replicas,
+// until collect returns true. Operations not collected keep running in background.
+func (r *Replicator) fanout(key []byte, op func(conn *BlockConnection) replicaResult, collect func(res replicaResult, n int) bool) error {
+	names, conns := r.c.replicas(key, r.op.Replicas)
+	if len(names) == 0 {
+		return errors.New("No drive in cluster")
+	}
+
+	results := make(chan replicaResult, len(names))
+	for k := range names {
+		go func(name string, conn *BlockConnection) {
+			res := op(conn)
+			res.drive = name
+			results <- res
+		}(names[k], conns[k])
+	}
+
+	for range names {
+		if collect(<-results, len(names)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// write runs Put or Delete on replicas, succeeds when WriteQuorum replicas acknowledged.
+func (r *Replicator) write(key []byte, op func(conn *BlockConnection) (Status, error)) (Status, error) {
+	qe := &QuorumError{Quorum: r.op.WriteQuorum, Failures: make(map[string]error)}
+	var failure Status
+	err := r.fanout(key, func(conn *BlockConnection) replicaResult {
+		status, err := op(conn)
+		return replicaResult{status: status, err: err}
+	}, func(res replicaResult, n int) bool {
+		if res.err == nil && res.status.Code == OK {
+			qe.Acks++
+			return qe.Acks >= qe.Quorum
+		}
+		st := res.status
+		if res.err != nil {
+			qe.Failures[res.drive] = res.err
+			if st.Code == OK {
+				st = Status{Code: ClientIOError, ErrorMsg: res.err.Error()}
+			}
+		} else {
+			qe.Failures[res.drive] = st
+		}
+		if len(qe.Failures) == 1 {
+			failure = st
+		}
+		// Stop if quorum can't be reached
+		return n-len(qe.Failures) < qe.Quorum
+	})
+	if err != nil {
+		return Status{Code: ClientInternalError, ErrorMsg: err.Error()}, err
+	}
+	if qe.Acks < qe.Quorum {
+		if len(qe.Failures) == 0 {
+			// Fewer replicas than quorum, no replica failed
+			failure = Status{Code: ClientInternalError, ErrorMsg: qe.Error()}
+		}
+		return failure, qe
+	}
+	return Status{Code: OK}, nil
+}
+
+// Put stores object on replicas of entry.Key, succeeds when WriteQuorum replicas acknowledged.
+// If quorum not reached, QuorumError is returned with Status of the first failed replica,
+// or ClientInternalError if there are fewer replicas than quorum.
+func (r *Replicator) Put(entry *Record) (Status, error) {
+	return r.write(entry.Key, func(conn *BlockConnection) (Status, error) {
+		return conn.Put(entry)
+	})
+}
+
+// Delete deletes object from replicas of entry.Key, succeeds when WriteQuorum replicas acknowledged.
+func (r *Replicator) Delete(entry *Record) (Status, error) {
+	return r.write(entry.Key, func(conn *BlockConnection) (Status, error) {
+		return conn.Delete(entry)
+	})
+}
+
+// Get reads object from replicas of key, until ReadQuorum replicas responded with object or
+// RemoteNotFound. Object with the greatest Version among responses is returned.
+func (r *Replicator) Get(key []byte) (*Record, Status, error) {
+	qe := &QuorumError{Quorum: r.op.ReadQuorum, Failures: make(map[string]error)}
+	var latest *Record
+	var notFound Status
+	err := r.fanout(key, func(conn *BlockConnection) replicaResult {
+		record, status, err := conn.Get(key)
+		return replicaResult{record: record, status: status, err: err}
+	}, func(res replicaResult, n int) bool {
+		switch {
+		case res.err == nil && res.status.Code == OK:
+			qe.Acks++
+			if latest == nil || bytes.Compare(res.record.Version, latest.Version) > 0 {
+				latest = res.record
+			}
+		case res.err == nil && res.status.Code == RemoteNotFound:
+			qe.Acks++
+			notFound = res.status
+		case res.err != nil:
+			qe.Failures[res.drive] = res.err
+		default:
+			qe.Failures[res.drive] = res.status
+		}
+		return qe.Acks >= qe.Quorum || n-len(qe.Failures) < qe.Quorum
+	})
+	if err != nil {
+		return nil, Status{Code: ClientInternalError, ErrorMsg: err.Error()}, err
+	}
+	if qe.Acks < qe.Quorum {
+		return nil, Status{Code: ClientIOError, ErrorMsg: qe.Error()}, qe
+	}
+	if latest == nil {
+		return nil, notFound, nil
+	}
+	return latest, Status{Code: OK}, nil
+}
@@ -0,0 +1,102 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReplicator(t *testing.T) {
+	sims, ops := startSimulators(t, 3)
+	for _, sim := range sims {
+		defer sim.Close()
+	}
+
+	c, err := NewCluster(ClusterOptions{Drives: ops})
+	if err != nil {
+		t.Fatal("Cluster create Failure", err)
+	}
+	defer c.Close()
+
+	if _, err = NewReplicator(c, ReplicationOptions{Replicas: 3, WriteQuorum: 4, ReadQuorum: 2}); err == nil {
+		t.Fatal("NewReplicator expected failure for WriteQuorum greater than Replicas")
+	}
+	if _, err = NewReplicator(c, ReplicationOptions{Replicas: 4, WriteQuorum: 4, ReadQuorum: 2}); err == nil {
+		t.Fatal("NewReplicator expected failure for WriteQuorum greater than number of drives")
+	}
+	r, err := NewReplicator(c, ReplicationOptions{Replicas: 3, WriteQuorum: 2, ReadQuorum: 2})
+	if err != nil {
+		t.Fatal("NewReplicator Failure", err)
+	}
+
+	key := []byte("replicated000")
+	replicas := r.Replicas(key)
+	if len(replicas) != 3 || replicas[0] != c.Drive(key) {
+		t.Fatal("Unexpected replicas", replicas)
+	}
+
+	status, err := r.Put(&Record{Key: key, Value: []byte("v1 value"), NewVersion: []byte("v1"), Force: true, Sync: SyncWriteThrough})
+	if err != nil || status.Code != OK {
+		t.Fatal("Replicator Put Failure", err, status.String())
+	}
+
+	// Newer version on one replica wins
+	owner := c.drives[replicas[0]]
+	status, err = owner.Put(&Record{Key: key, Value: []byte("v2 value"), NewVersion: []byte("v2"), Force: true, Sync: SyncWriteThrough})
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking Put Failure", err, status.String())
+	}
+	r.op.ReadQuorum = 3
+	record, status, err := r.Get(key)
+	if err != nil || status.Code != OK || !bytes.Equal(record.Version, []byte("v2")) {
+		t.Fatal("Replicator Get expected latest version", err, status.String())
+	}
+
+	// Quorum not reached with fewer drives than quorum
+	r.op.Replicas, r.op.WriteQuorum = 4, 4
+	status, err = r.Put(&Record{Key: key, Value: []byte("v3 value"), NewVersion: []byte("v3"), Force: true})
+	if _, ok := err.(*QuorumError); !ok || status.Code != ClientInternalError {
+		t.Fatal("Replicator Put expected ClientInternalError with QuorumError", err, status.String())
+	}
+	r.op.Replicas = 3
+
+	// Quorum not reached with one replica down
+	r.op.WriteQuorum = 3
+	for k, op := range ops {
+		if DriveName(op) == replicas[2] {
+			sims[k].Close()
+		}
+	}
+	// All 3 drives are replicas of every key
+	status, err = r.Put(&Record{Key: []byte("replicated001"), Value: []byte("v1 value"), NewVersion: []byte("v1"), Force: true})
+	if _, ok := err.(*QuorumError); !ok || status.Code == OK {
+		t.Fatal("Replicator Put expected QuorumError", err, status.String())
+	}
+
+	r.op.WriteQuorum = 2
+	status, err = r.Delete(&Record{Key: key, Force: true})
+	if err != nil || status.Code != OK {
+		t.Fatal("Replicator Delete Failure", err, status.String())
+	}
+	r.op.ReadQuorum = 2
+	_, status, err = r.Get(key)
+	if err != nil || status.Code != RemoteNotFound {
+		t.Fatal("Replicator Get expected RemoteNotFound", err, status.String())
+	}
+}
@@ -0,0 +1,108 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"errors"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// errPlainSecure is returned when PIN or SECURITY operation is about to be sent over plain connection.
+var errPlainSecure = errors.New("PIN and SECURITY operations require TLS connection, set UseSSL or AutoTLS")
+
+// tlsPort returns TLS port of device from handshake, 0 if not reported.
+func (ns *networkService) tlsPort() int {
+	ns.stateMu.Lock()
+	defer ns.stateMu.Unlock()
+	if ns.device.Configuration == nil {
+		return 0
+	}
+	return int(ns.device.Configuration.TLSPort)
+}
+
+// secureService returns network service over TLS for PIN and SECURITY operations.
+// If connection is not TLS, TLS side connection to device TLS port is opened on first use
+// with ClientOptions.AutoTLS, or errPlainSecure is returned. PIN is never sent over plain connection.
+func (conn *NonBlockConnection) secureService() (*networkService, error) {
+	if conn.service.option.UseSSL {
+		return conn.service, nil
+	}
+	if !conn.service.option.AutoTLS {
+		return nil, errPlainSecure
+	}
+
+	conn.secureMu.Lock()
+	defer conn.secureMu.Unlock()
+
+	if err := conn.service.fatalErr(); err != nil {
+		return nil, errors.New("Can't submit, network service has fatal error: " + err.Error())
+	}
+	if conn.secure != nil {
+		if conn.secure.fatalErr() == nil {
+			return conn.secure, nil
+		}
+		// Side connection broken, open a new one
+		conn.secure.close()
+		conn.secure = nil
+	}
+
+	port := conn.service.tlsPort()
+	if port <= 0 {
+		return nil, errors.New("Device reports no TLS port")
+	}
+
+	op := conn.service.option
+	op.Port = port
+	op.UseSSL = true
+	op.AutoTLS = false
+	op.ReceiveLoop = true
+	op.Reconnect = nil
+	op.StatusEvents = nil
+	ns, err := newNetworkService(op)
+	if err != nil {
+		return nil, err
+	}
+	// Cluster version set by client overrides the one from handshake
+	ns.setClusterVersion(conn.service.getClusterVersion())
+	conn.secure = ns
+	return ns, nil
+}
+
+// submitSecure submits PIN or SECURITY operation over TLS connection.
+func (conn *NonBlockConnection) submitSecure(msg *kproto.Message, cmd *kproto.Command, h *ResponseHandler) error {
+	ns, err := conn.secureService()
+	if err != nil {
+		klog.Error(err.Error())
+		if h != nil {
+			h.fail(Status{Code: ClientInternalError, ErrorMsg: err.Error()})
+		}
+		return err
+	}
+	return ns.submit(msg, cmd, nil, h)
+}
+
+// closeSecure closes TLS side connection if opened.
+func (conn *NonBlockConnection) closeSecure() {
+	conn.secureMu.Lock()
+	defer conn.secureMu.Unlock()
+	if conn.secure != nil {
+		conn.secure.close()
+		conn.secure = nil
+	}
+}
@@ -0,0 +1,118 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+
+	"github.com/Kinetic/kinetic-go/simulator"
+)
+
+func TestAutoTLS(t *testing.T) {
+	device := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kinetic"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, nil)
+	sim, err := simulator.New(simulator.Options{
+		Host: option.Host,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{device.der}, PrivateKey: device.key}},
+		},
+	})
+	if err != nil {
+		t.Fatal("Simulator start failure", err)
+	}
+	defer sim.Close()
+
+	op := option
+	op.Port = sim.Port()
+	plain, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer plain.Close()
+
+	// PIN is never sent over plain connection
+	if _, err = plain.SetLockPin(nil, []byte("1234")); err == nil {
+		t.Fatal("SetLockPin over plain connection expect error")
+	}
+	if _, err = plain.LockDevice([]byte("1234")); err == nil {
+		t.Fatal("LockDevice over plain connection expect error")
+	}
+	if status, err := plain.NoOp(); err != nil || status.Code != OK {
+		t.Fatal("Plain connection broken after refused PIN operation", err, status.String())
+	}
+
+	op.AutoTLS = true
+	op.TLS = &TLSOptions{Fingerprints: [][]byte{CertificateFingerprint(device.cert)}}
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	if status, err := conn.SetLockPin(nil, []byte("1234")); err != nil || status.Code != OK {
+		t.Fatal("SetLockPin over TLS side connection Failure", err, status.String())
+	}
+	if status, err := conn.LockDevice([]byte("1234")); err != nil || status.Code != OK {
+		t.Fatal("LockDevice over TLS side connection Failure", err, status.String())
+	}
+	if status, _ := conn.NoOp(); status.Code != RemoteDeviceLocked {
+		t.Fatal("NoOp on locked device expect REMOTE_DEVICE_LOCKED, actual", status.String())
+	}
+	if status, err := conn.UnlockDevice([]byte("1234")); err != nil || status.Code != OK {
+		t.Fatal("UnlockDevice over TLS side connection Failure", err, status.String())
+	}
+	if status, err := conn.SetLockPin([]byte("1234"), nil); err != nil || status.Code != OK {
+		t.Fatal("SetLockPin over TLS side connection Failure", err, status.String())
+	}
+	if conn.nbc.secure == nil || !conn.nbc.secure.option.UseSSL || conn.nbc.secure.option.Port != sim.TLSPort() {
+		t.Fatal("TLS side connection not opened to device TLS port")
+	}
+
+	// Side connection follows client cluster version
+	if status, err := conn.SetClusterVersion(3); err != nil || status.Code != OK {
+		t.Fatal("SetClusterVersion Failure", err, status.String())
+	}
+	conn.SetClientClusterVersion(3)
+	if status, err := conn.SetErasePin(nil, nil); err != nil || status.Code != OK {
+		t.Fatal("SetErasePin after SetClientClusterVersion Failure", err, status.String())
+	}
+
+	// Side connection reopened after broken, with client cluster version
+	conn.nbc.secure.close()
+	conn.SetClientClusterVersion(4)
+	if status, _ := conn.SetErasePin(nil, nil); status.Code != RemoteClusterVersionMismatch {
+		t.Fatal("SetErasePin expect REMOTE_CLUSTER_VERSION_MISMATCH, actual", status.String())
+	}
+	conn.SetClientClusterVersion(3)
+	if status, err := conn.SetErasePin(nil, nil); err != nil || status.Code != OK {
+		t.Fatal("SetErasePin after side connection closed Failure", err, status.String())
+	}
+
+	conn.Close()
+	if conn.nbc.secure != nil {
+		t.Fatal("TLS side connection not closed with connection")
+	}
+}
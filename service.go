@@ -18,12 +18,13 @@
 package kinetic
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/binary"
 	"errors"
-	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -41,10 +42,8 @@ const (
 	DefaultRequestTimeout = 50 * time.Second
 )
 
-var (
-	connectionTimeout = DefaultConnectionTimeout
-	requestTimeout    = DefaultRequestTimeout
-)
+// errReceiveIdle is returned by receive when read deadline passed before any data received.
+var errReceiveIdle = errors.New("No data received before read deadline")
 
 func newMessage(t kproto.Message_AuthType) *kproto.Message {
 	msg := &kproto.Message{
@@ -69,6 +68,7 @@ type networkService struct {
 	rxMu           sync.Mutex
 	txMu           sync.Mutex
 	mapMu          sync.Mutex
+	stateMu        sync.Mutex // Protects connection state, fatal, connID, clusterVersion etc.
 	conn           net.Conn
 	clusterVersion int64                      // Cluster version
 	seq            int64                      // Operation sequence ID
@@ -77,39 +77,34 @@ type networkService struct {
 	hmap           map[int64]*ResponseHandler // Message handler map
 	fatal          bool                       // Network has fatal failure
 	fatalError     error                      // Network fatal error details
+	terminated     bool                       // Connection closed by client, or terminated by device
+	hibernating    bool                       // Device announced hibernate, cleared by next response
 	device         Log                        // Store device information from handshake package
+	connTimeout    time.Duration              // Timeout to make network connection
+	tlsConfig      *tls.Config                // TLS configuration if UseSSL
+	reqTimeout     time.Duration              // Default timeout for each request
+	loop           bool                       // Responses received by receiveLoop goroutine
+	loopDone       chan struct{}              // Closed when receiveLoop exits
+	reconnect      *ReconnectOptions          // Reconnect options, set after first handshake done
+	reconnecting   chan struct{}              // Not nil during reconnect, closed when reconnect done
+	closed         chan struct{}              // Closed when network service closed
+	listening      chan struct{}              // One slot, held while a Listen goroutine reads
+	closeOnce      sync.Once
 }
 
 func newNetworkService(op ClientOptions) (*networkService, error) {
-	var conn net.Conn
-	var err error
-
+	connectionTimeout := DefaultConnectionTimeout
 	if op.Timeout > 0 {
 		// Timeout value in ClientOptions is in Millisecond
 		connectionTimeout = time.Duration(op.Timeout) * time.Millisecond
 	}
+	requestTimeout := DefaultRequestTimeout
 	if op.RequestTimeout > 0 {
 		// RequestTimeout value in ClientOptions is in Millisecond
 		requestTimeout = time.Duration(op.RequestTimeout) * time.Millisecond
 	}
 
-	target := fmt.Sprintf("%s:%d", op.Host, op.Port)
-	if op.UseSSL {
-		// TODO: Need to enable verify certification later
-		config := tls.Config{InsecureSkipVerify: true}
-		d := &net.Dialer{Timeout: connectionTimeout}
-		conn, err = tls.DialWithDialer(d, "tcp", target, &config)
-	} else {
-		conn, err = net.DialTimeout("tcp", target, connectionTimeout)
-	}
-
-	if err != nil {
-		klog.Error("Can't establish connection to ", op.Host, err)
-		return nil, err
-	}
-
 	ns := &networkService{
-		conn:           conn,
 		clusterVersion: 0,
 		seq:            0,
 		connID:         -1,
@@ -117,16 +112,35 @@ func newNetworkService(op ClientOptions) (*networkService, error) {
 		hmap:           make(map[int64]*ResponseHandler),
 		fatal:          false,
 		fatalError:     nil,
+		connTimeout:    connectionTimeout,
+		reqTimeout:     requestTimeout,
+		closed:         make(chan struct{}),
+		listening:      make(chan struct{}, 1),
 	}
 
+	if op.UseSSL {
+		config, err := op.TLS.tlsConfig(op.Host)
+		if err != nil {
+			klog.Error("Can't load TLS configuration for ", op.Host, err)
+			return nil, err
+		}
+		ns.tlsConfig = config
+	}
+
+	conn, err := ns.dial()
+	if err != nil {
+		klog.Error("Can't establish connection to ", op.Host, err)
+		return nil, err
+	}
+	ns.conn = conn
+
 	ns.rxMu.Lock()
-	// Do the handshake.
-	// Device Configuration and Limits from handshake will be stored in networkService.device
-	_, _, _, err = ns.receive()
+	err = ns.handshake()
 	ns.rxMu.Unlock()
 
 	if err != nil {
-		klog.Error("Can't establish connection to %s", op.Host)
+		klog.Errorf("Can't establish connection to %s", op.Host)
+		conn.Close()
 		return nil, err
 	}
 
@@ -140,12 +154,152 @@ func newNetworkService(op ClientOptions) (*networkService, error) {
 	klog.Debugf("    Port: %d", ns.device.Configuration.Port)
 	klog.Debugf("    TlsPort: %d", ns.device.Configuration.TLSPort)
 	klog.Debugf("    CurrentPowerLevel : %s", ns.device.Configuration.CurrentPowerLevel.String())
-	klog.Debugf("    Connection Timeout : %d s", connectionTimeout/time.Second)
-	klog.Debugf("    Operation Timeout : %d s", requestTimeout/time.Second)
+	klog.Debugf("    Connection Timeout : %d s", ns.connTimeout/time.Second)
+	klog.Debugf("    Operation Timeout : %d s", ns.reqTimeout/time.Second)
+
+	// Reconnect requires receiveLoop, requests in flight are resubmitted without listen.
+	// StatusEvents requires receiveLoop, unsolicited status arrives without request.
+	ns.reconnect = op.Reconnect
+	if op.ReceiveLoop || op.Reconnect != nil || op.StatusEvents != nil {
+		ns.loop = true
+		ns.loopDone = make(chan struct{})
+		go ns.receiveLoop()
+	}
 
 	return ns, nil
 }
 
+// dial makes network connection to kinetic device, by ClientOptions.Dialer if set.
+// For UseSSL, TLS handshake is made over the network connection.
+func (ns *networkService) dial() (net.Conn, error) {
+	target := net.JoinHostPort(ns.option.Host, strconv.Itoa(ns.option.Port))
+	ctx, cancel := context.WithTimeout(context.Background(), ns.connTimeout)
+	defer cancel()
+
+	dial := ns.option.Dialer
+	if dial == nil {
+		d := &net.Dialer{}
+		dial = d.DialContext
+	}
+	conn, err := dial(ctx, "tcp", target)
+	if err != nil {
+		return nil, err
+	}
+	if !ns.option.UseSSL {
+		return conn, nil
+	}
+
+	config := ns.tlsConfig
+	if config.ServerName == "" && !config.InsecureSkipVerify {
+		// Unlike tls.Dial, tls.Client doesn't take ServerName from address
+		config = config.Clone()
+		config.ServerName = ns.option.Host
+	}
+	tconn := tls.Client(conn, config)
+	conn.SetDeadline(time.Now().Add(ns.connTimeout))
+	err = tconn.Handshake()
+	conn.SetDeadline(time.Time{})
+	if err == nil {
+		err = ns.option.TLS.verifyPinned(tconn)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tconn, nil
+}
+
+// handshake receives the UNSOLICITEDSTATUS message kinetic device sends for new connection.
+// Device Configuration and Limits from handshake will be stored in networkService.device
+func (ns *networkService) handshake() error {
+	ns.stateMu.Lock()
+	ns.connID = -1
+	ns.stateMu.Unlock()
+
+	ns.conn.SetReadDeadline(time.Now().Add(ns.reqTimeout))
+	_, _, _, err := ns.receive()
+	if err == nil && ns.option.UseSSL && ns.option.TLS != nil && ns.option.TLS.VerifyDevice {
+		err = ns.verifyDevice()
+	}
+	return err
+}
+
+// ioError handles network I/O failure. All requests in queue fail with status s and network
+// service is marked as fatal. If reconnect enabled, reconnect starts in background instead.
+func (ns *networkService) ioError(s Status, err error) {
+	if ns.startReconnect(err) {
+		return
+	}
+	ns.clientError(s, nil)
+	ns.setFatal(err)
+}
+
+// setFatal marks network service as failed, all later submit will fail with err.
+// Only the first error is kept.
+func (ns *networkService) setFatal(err error) {
+	ns.stateMu.Lock()
+	if !ns.fatal {
+		ns.fatal = true
+		ns.fatalError = err
+	}
+	ns.stateMu.Unlock()
+}
+
+// limits returns device Limits from handshake, nil if device doesn't report Limits.
+func (ns *networkService) limits() *LimitsLog {
+	ns.stateMu.Lock()
+	defer ns.stateMu.Unlock()
+	return ns.device.Limits
+}
+
+// maxKeySize returns MaxKeySize limit of device, or DefaultMaxKeySize if not reported.
+func (ns *networkService) maxKeySize() int {
+	if limits := ns.limits(); limits != nil && limits.MaxKeySize > 0 {
+		return int(limits.MaxKeySize)
+	}
+	return DefaultMaxKeySize
+}
+
+// maxValueSize returns MaxValueSize limit of device, or DefaultMaxValueSize if not reported.
+func (ns *networkService) maxValueSize() int {
+	if limits := ns.limits(); limits != nil && limits.MaxValueSize > 0 {
+		return int(limits.MaxValueSize)
+	}
+	return DefaultMaxValueSize
+}
+
+// maxKeyRangeCount returns MaxKeyRangeCount limit of device, or DefaultKeyRangeCount if not reported.
+func (ns *networkService) maxKeyRangeCount() int32 {
+	if limits := ns.limits(); limits != nil && limits.MaxKeyRangeCount > 0 {
+		return int32(limits.MaxKeyRangeCount)
+	}
+	return DefaultKeyRangeCount
+}
+
+// setClusterVersion sets cluster version sent with following requests.
+func (ns *networkService) setClusterVersion(version int64) {
+	ns.stateMu.Lock()
+	ns.clusterVersion = version
+	ns.stateMu.Unlock()
+}
+
+// getClusterVersion returns cluster version sent with requests.
+func (ns *networkService) getClusterVersion() int64 {
+	ns.stateMu.Lock()
+	defer ns.stateMu.Unlock()
+	return ns.clusterVersion
+}
+
+// fatalErr returns the fatal error of network service, nil if network service is healthy.
+func (ns *networkService) fatalErr() error {
+	ns.stateMu.Lock()
+	defer ns.stateMu.Unlock()
+	if ns.fatal {
+		return ns.fatalError
+	}
+	return nil
+}
+
 // When client network service has error, call error handling
 // from all Messagehandler current in Queue.
 func (ns *networkService) clientError(s Status, mh *ResponseHandler) {
@@ -162,25 +316,85 @@ func (ns *networkService) clientError(s Status, mh *ResponseHandler) {
 }
 
 func (ns *networkService) listen() error {
-	if ns.fatal {
-		return errors.New("Can't listen, network service has fatal error: " + ns.fatalError.Error())
+	if err := ns.fatalErr(); err != nil {
+		return errors.New("Can't listen, network service has fatal error: " + err.Error())
 	}
 
-	ns.mapMu.Lock()
-	if len(ns.hmap) == 0 {
-		ns.mapMu.Unlock()
+	if ns.loop {
+		// Response will be received and handled by receiveLoop
 		return nil
 	}
-	ns.mapMu.Unlock()
 
-	ns.rxMu.Lock()
-	msg, cmd, value, err := ns.receive()
-	ns.rxMu.Unlock()
-	if err != nil {
-		klog.Error("Network Service listen error")
-		return err
+	var msg *kproto.Message
+	var cmd *kproto.Command
+	var value []byte
+	for {
+		ns.rxMu.Lock()
+		ns.mapMu.Lock()
+		waiting := len(ns.hmap)
+		ns.mapMu.Unlock()
+		if waiting == 0 {
+			ns.rxMu.Unlock()
+			return nil
+		}
+
+		// Read deadline only bounds each wait, requests expire by their own timer
+		// and a quiet connection is not a failure.
+		var err error
+		ns.conn.SetReadDeadline(time.Now().Add(ns.reqTimeout))
+		msg, cmd, value, err = ns.receive()
+		ns.rxMu.Unlock()
+		if err == errReceiveIdle {
+			continue
+		}
+		if err != nil {
+			klog.Error("Network Service listen error")
+			return err
+		}
+		break
+	}
+
+	if !ns.dispatch(msg, cmd, value) {
+		// This is an unexpected packet. Each listen() call expect remove one ResponseHandler from hmap.
+		// So need to fire another listen() to make sure ResponseHandler in hmap got chance to exit.
+		// Either by receive correct packet, or network read failure.
+		go ns.listen()
+	}
+
+	return nil
+}
+
+// receiveLoop keeps receiving response messages and dispatching them to ResponseHandler in hmap,
+// so requests can be pipelined without calling listen. It exits when network service fails or closed.
+func (ns *networkService) receiveLoop() {
+	defer close(ns.loopDone)
+	for {
+		ns.rxMu.Lock()
+		ns.conn.SetReadDeadline(time.Time{})
+		msg, cmd, value, err := ns.receive()
+		ns.rxMu.Unlock()
+		if err != nil && ns.waitReconnect() {
+			// Reconnected, continue receive from new connection
+			continue
+		}
+		if err != nil {
+			fatal := ns.fatalErr()
+			if fatal == nil {
+				// Reconnect already finished before waitReconnect
+				continue
+			}
+			// Fail requests submitted while network service failing
+			s := Status{Code: ClientIOError, ErrorMsg: "Network service has fatal error: " + fatal.Error()}
+			ns.clientError(s, nil)
+			return
+		}
+		ns.dispatch(msg, cmd, value)
 	}
+}
 
+// dispatch finds the ResponseHandler by AckSequence of received message and handles the message.
+// Returns false if no ResponseHandler waiting for this message.
+func (ns *networkService) dispatch(msg *kproto.Message, cmd *kproto.Command, value []byte) bool {
 	if cmd.GetHeader() != nil {
 		klog.Debug("Kinetic response received ", cmd.GetHeader().GetMessageType().String(),
 			", AckSeq = ", cmd.GetHeader().GetAckSequence(),
@@ -191,8 +405,11 @@ func (ns *networkService) listen() error {
 			", StatusMessage = ", cmd.GetStatus().GetStatusMessage())
 	}
 
-	// For UNSOLICITEDSTATUS, command may not have Header or AckSequence, set the ack to -1 so
-	// no ResponseHandler will be found from hmap table.
+	if msg.GetAuthType() == kproto.Message_UNSOLICITEDSTATUS {
+		ns.unsolicited(cmd)
+		return false
+	}
+
 	var ack int64 = -1
 	if cmd.GetHeader() != nil {
 		ack = cmd.GetHeader().GetAckSequence()
@@ -200,38 +417,59 @@ func (ns *networkService) listen() error {
 
 	ns.mapMu.Lock()
 	h, ok := ns.hmap[ack]
+	if ok {
+		delete(ns.hmap, ack)
+	}
 	ns.mapMu.Unlock()
 	if ok == false {
-		// It's high chance this is an UNSOLICITEDSTATUS message, display the Status.
 		klog.Errorf("Couldn't find a handler for acksequence %d, status=%s", ack, getStatusFromProto(cmd).String())
-		// This is an unexpected packet. Each listen() call expect remove one ResponseHandler from hmap.
-		// So need to fire another listen() to make sure ResponseHandler in hmap got chance to exit.
-		// Either by receive correct packet, or network read failure.
-		go ns.listen()
-		return nil
+		return false
 	}
 
-	h.handle(cmd, value)
+	// Device responds, it is not hibernating any more
+	ns.stateMu.Lock()
+	ns.hibernating = false
+	ns.stateMu.Unlock()
 
-	ns.mapMu.Lock()
-	delete(ns.hmap, ack)
-	ns.mapMu.Unlock()
+	if h.verify && cmd.GetStatus().GetCode() == kproto.Command_Status_SUCCESS {
+		if s := verifyTag(cmd, value); s != nil {
+			klog.Error(s.ErrorMsg)
+			h.fail(*s)
+			return true
+		}
+	}
 
-	return nil
+	h.handle(cmd, value)
+
+	return true
 }
 
 // submit will send the message to kinetic device, insert ResponseHandler for this message sequence number.
 // ResponseHandler can be nil if the message no require for Ack, eg batch PUT / DELETE.
+// The request uses the connection request timeout.
 func (ns *networkService) submit(msg *kproto.Message, cmd *kproto.Command, value []byte, h *ResponseHandler) error {
-	if ns.fatal {
-		return errors.New("Can't submit, network service has fatal error: " + ns.fatalError.Error())
+	return ns.submitTimeout(msg, cmd, value, h, ns.reqTimeout)
+}
+
+// submitTimeout is submit with request timeout overrides the connection request timeout.
+// If no response received within timeout, only h fails with ClientRequestTimeout status.
+func (ns *networkService) submitTimeout(msg *kproto.Message, cmd *kproto.Command, value []byte, h *ResponseHandler, timeout time.Duration) error {
+	if err := ns.waitReady(timeout); err != nil {
+		return err
 	}
 
 	ns.txMu.Lock()
+	defer ns.txMu.Unlock()
+
+	ns.stateMu.Lock()
+	connID, clusterVersion := ns.connID, ns.clusterVersion
+	ns.stateMu.Unlock()
 
-	cmd.GetHeader().ConnectionID = &ns.connID
-	cmd.GetHeader().Sequence = &ns.seq
-	cmd.GetHeader().ClusterVersion = &ns.clusterVersion
+	cmd.GetHeader().ConnectionID = &connID
+	// Copy of sequence, so Sequence in cmd is the one sent after ns.seq increased
+	seq := ns.seq
+	cmd.GetHeader().Sequence = &seq
+	cmd.GetHeader().ClusterVersion = &clusterVersion
 
 	cmdBytes, err := proto.Marshal(cmd)
 	if err != nil {
@@ -249,25 +487,104 @@ func (ns *networkService) submit(msg *kproto.Message, cmd *kproto.Command, value
 
 	klog.Debug("Kinetic message send ", cmd.GetHeader().GetMessageType().String(), " Seq = ", ns.seq)
 
-	err = ns.send(msg, value)
-
-	if err != nil {
-		return err
-	}
-
+	// ResponseHandler must be in hmap before send, response may be received by
+	// receiveLoop before send returns.
+	deadline := time.Now().Add(timeout)
 	if h != nil {
 		ns.mapMu.Lock()
+		h.seq = ns.seq
+		h.deadline = deadline
+		if ns.retryable(cmd) {
+			h.msg, h.cmd = msg, cmd
+		}
+		h.verify = ns.option.ComputeTag && verifiable[cmd.GetHeader().GetMessageType()] &&
+			!cmd.GetBody().GetKeyValue().GetMetadataOnly()
 		ns.hmap[ns.seq] = h
 		ns.mapMu.Unlock()
+		if err = ns.fatalErr(); err != nil {
+			ns.cancel(h, Status{Code: ClientIOError, ErrorMsg: "Network service has fatal error: " + err.Error()})
+			return errors.New("Can't submit, network service has fatal error: " + err.Error())
+		}
+		h.expire(timeout, func() {
+			ns.cancel(h, Status{Code: ClientRequestTimeout, ErrorMsg: "Request timeout"})
+		})
+	}
+
+	err = ns.send(msg, value, deadline)
+
+	if err != nil {
+		if h != nil {
+			ns.cancel(h, Status{Code: ClientIOError, ErrorMsg: "Network I/O write error, " + err.Error()})
+		}
+		return err
 	}
 
 	ns.seq++
-	ns.txMu.Unlock()
 
 	return nil
 }
 
-func (ns *networkService) send(msg *kproto.Message, value []byte) error {
+// submitContext submits the message like submit. The deadline of ctx overrides the connection
+// request timeout, and is sent to kinetic device as the request timeout. When ctx is done
+// before response received, h will be removed from hmap and fail with ClientRequestCanceled
+// or ClientRequestTimeout status.
+func (ns *networkService) submitContext(ctx context.Context, msg *kproto.Message, cmd *kproto.Command, value []byte, h *ResponseHandler) error {
+	if err := ctx.Err(); err != nil {
+		if h != nil {
+			h.fail(contextStatus(err))
+		}
+		return err
+	}
+
+	timeout := ns.reqTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = deadline.Sub(time.Now())
+		// Timeout in kinetic Header is in millisecond
+		ms := int64(timeout / time.Millisecond)
+		if ms < 1 {
+			ms = 1
+		}
+		cmd.GetHeader().Timeout = &ms
+	}
+
+	err := ns.submitTimeout(msg, cmd, value, h, timeout)
+	if err != nil || h == nil || ctx.Done() == nil {
+		return err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			ns.cancel(h, contextStatus(ctx.Err()))
+		case <-h.done:
+		}
+	}()
+
+	return nil
+}
+
+// cancel removes h from hmap and fails it with status s.
+// Returns false if h already finished before cancel.
+func (ns *networkService) cancel(h *ResponseHandler, s Status) bool {
+	ns.mapMu.Lock()
+	if cur, ok := ns.hmap[h.seq]; ok && cur == h {
+		delete(ns.hmap, h.seq)
+	}
+	ns.mapMu.Unlock()
+
+	return h.fail(s)
+}
+
+// contextStatus converts context error to client Status.
+func contextStatus(err error) Status {
+	if err == context.DeadlineExceeded {
+		return Status{Code: ClientRequestTimeout, ErrorMsg: "Request timeout, " + err.Error()}
+	}
+	return Status{Code: ClientRequestCanceled, ErrorMsg: "Request canceled, " + err.Error()}
+}
+
+// send writes msg and value to device, write fails if not done by deadline.
+func (ns *networkService) send(msg *kproto.Message, value []byte, deadline time.Time) error {
 	msgBytes, err := proto.Marshal(msg)
 	if err != nil {
 		s := Status{Code: ClientInternalError, ErrorMsg: "Error marshl Kinetic Message"}
@@ -275,8 +592,8 @@ func (ns *networkService) send(msg *kproto.Message, value []byte) error {
 		return err
 	}
 
-	// Set timeout for send packet
-	ns.conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	// Request timeout covers send of packet
+	ns.conn.SetWriteDeadline(deadline)
 
 	// Construct message header 9 bytes
 	header := make([]byte, 9)
@@ -293,9 +610,7 @@ func (ns *networkService) send(msg *kproto.Message, value []byte) error {
 	if err != nil {
 		klog.Error("Network I/O write error, " + err.Error())
 		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O write error, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
+		ns.ioError(s, err)
 		return err
 	}
 
@@ -303,18 +618,21 @@ func (ns *networkService) send(msg *kproto.Message, value []byte) error {
 }
 
 func (ns *networkService) receive() (*kproto.Message, *kproto.Command, []byte, error) {
-	// Set timeout for receive packet
-	ns.conn.SetReadDeadline(time.Now().Add(requestTimeout))
-
 	header := make([]byte, 9)
 
-	_, err := io.ReadFull(ns.conn, header[0:])
+	n, err := io.ReadFull(ns.conn, header[0:])
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && n == 0 {
+		// Nothing received before read deadline, connection is still in sync
+		return nil, nil, nil, errReceiveIdle
+	}
 	if err != nil {
+		if ns.fatalErr() != nil {
+			// Network service already failed or closed, nothing more to report
+			return nil, nil, nil, err
+		}
 		klog.Error("Network I/O read error, " + err.Error())
 		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
+		ns.ioError(s, err)
 		return nil, nil, nil, err
 	}
 
@@ -322,9 +640,7 @@ func (ns *networkService) receive() (*kproto.Message, *kproto.Command, []byte, e
 	if magic != 'F' {
 		klog.Error("Network I/O read error Header wrong magic")
 		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error Header wrong magic"}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = errors.New("Wrong magic number")
+		ns.ioError(s, errors.New("Wrong magic number"))
 		return nil, nil, nil, errors.New("Network I/O read error Header wrong magic")
 	}
 
@@ -336,9 +652,7 @@ func (ns *networkService) receive() (*kproto.Message, *kproto.Command, []byte, e
 	if err != nil {
 		klog.Error("Network I/O read error receive Kinetic Header, " + err.Error())
 		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error receive Kinetic Header, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
+		ns.ioError(s, err)
 		return nil, nil, nil, err
 	}
 
@@ -347,9 +661,7 @@ func (ns *networkService) receive() (*kproto.Message, *kproto.Command, []byte, e
 	if err != nil {
 		klog.Error("Network I/O read error receive Kinetic Header, " + err.Error())
 		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error reaceive Kinetic Message, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
+		ns.ioError(s, err)
 		return nil, nil, nil, err
 	}
 
@@ -365,13 +677,13 @@ func (ns *networkService) receive() (*kproto.Message, *kproto.Command, []byte, e
 	if err != nil {
 		klog.Error("Network I/O read error parsing Kinetic Command, " + err.Error())
 		s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error parsing Kinetic Command, " + err.Error()}
-		ns.clientError(s, nil)
-		ns.fatal = true
-		ns.fatalError = err
+		ns.ioError(s, err)
 		return nil, nil, nil, err
 	}
 
 	if cmd.Header != nil && cmd.Header.ConnectionID != nil {
+		// connID and clusterVersion are used by submit to build request message
+		ns.stateMu.Lock()
 		if ns.connID < 0 {
 			// This is handshake packet
 			ns.device = getLogFromProto(cmd)
@@ -382,6 +694,7 @@ func (ns *networkService) receive() (*kproto.Message, *kproto.Command, []byte, e
 			}
 		}
 		ns.connID = cmd.GetHeader().GetConnectionID()
+		ns.stateMu.Unlock()
 	}
 
 	if valueLen > 0 {
@@ -390,9 +703,7 @@ func (ns *networkService) receive() (*kproto.Message, *kproto.Command, []byte, e
 		if err != nil {
 			klog.Error("Network I/O read error parsing Kinetic Value, " + err.Error())
 			s := Status{Code: ClientIOError, ErrorMsg: "Network I/O read error parsing Kinetic Value, " + err.Error()}
-			ns.clientError(s, nil)
-			ns.fatal = true
-			ns.fatalError = err
+			ns.ioError(s, err)
 			return nil, nil, nil, err
 		}
 
@@ -403,6 +714,16 @@ func (ns *networkService) receive() (*kproto.Message, *kproto.Command, []byte, e
 }
 
 func (ns *networkService) close() {
+	ns.stateMu.Lock()
+	ns.terminated = true
+	ns.stateMu.Unlock()
+	ns.setFatal(errors.New("Connection closed"))
+	ns.closeOnce.Do(func() { close(ns.closed) })
+	ns.stateMu.Lock()
 	ns.conn.Close()
+	ns.stateMu.Unlock()
+	if ns.loop {
+		<-ns.loopDone
+	}
 	klog.Debugf("Connection to %s closed", ns.option.Host)
 }
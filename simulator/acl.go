@@ -0,0 +1,132 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package simulator
+
+import (
+	"bytes"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// scope limits a set of permissions to keys holding value at offset.
+type scope struct {
+	offset      int64
+	value       []byte
+	permissions []kproto.Command_Security_ACL_Permission
+	tlsRequired bool
+}
+
+// acl holds HMAC key and permission scopes of one user identity.
+type acl struct {
+	key    []byte
+	scopes []scope
+}
+
+var allPermissions = []kproto.Command_Security_ACL_Permission{
+	kproto.Command_Security_ACL_READ,
+	kproto.Command_Security_ACL_WRITE,
+	kproto.Command_Security_ACL_DELETE,
+	kproto.Command_Security_ACL_RANGE,
+	kproto.Command_Security_ACL_SETUP,
+	kproto.Command_Security_ACL_P2POP,
+	kproto.Command_Security_ACL_GETLOG,
+	kproto.Command_Security_ACL_SECURITY,
+	kproto.Command_Security_ACL_POWER_MANAGEMENT,
+}
+
+// newDefaultACL builds an ACL which allows every operation on every key.
+func newDefaultACL(key []byte) *acl {
+	return &acl{
+		key:    key,
+		scopes: []scope{scope{permissions: allPermissions}},
+	}
+}
+
+func newACLFromProto(pacl *kproto.Command_Security_ACL) *acl {
+	a := &acl{
+		key:    pacl.GetKey(),
+		scopes: make([]scope, len(pacl.GetScope())),
+	}
+	for k, ps := range pacl.GetScope() {
+		a.scopes[k] = scope{
+			offset:      ps.GetOffset(),
+			value:       ps.GetValue(),
+			permissions: ps.GetPermission(),
+			tlsRequired: ps.GetTlsRequired(),
+		}
+	}
+	return a
+}
+
+// match reports whether key falls into the scope. Operations without key
+// only check the permission.
+func (sc *scope) match(key []byte) bool {
+	if key == nil || len(sc.value) == 0 {
+		return true
+	}
+	end := sc.offset + int64(len(sc.value))
+	if sc.offset < 0 || int64(len(key)) < end {
+		return false
+	}
+	return bytes.Equal(key[sc.offset:end], sc.value)
+}
+
+// permitted reports whether perm is granted on key for this identity.
+func (a *acl) permitted(perm kproto.Command_Security_ACL_Permission, key []byte, isTLS bool) bool {
+	for k := range a.scopes {
+		sc := &a.scopes[k]
+		if sc.tlsRequired && !isTLS {
+			continue
+		}
+		if !sc.match(key) {
+			continue
+		}
+		for _, p := range sc.permissions {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requiredPermission returns the permission needed for message type t, and false
+// if the operation doesn't require any permission.
+func requiredPermission(t kproto.Command_MessageType) (kproto.Command_Security_ACL_Permission, bool) {
+	switch t {
+	case kproto.Command_GET, kproto.Command_GETNEXT, kproto.Command_GETPREVIOUS, kproto.Command_GETVERSION:
+		return kproto.Command_Security_ACL_READ, true
+	case kproto.Command_PUT:
+		return kproto.Command_Security_ACL_WRITE, true
+	case kproto.Command_DELETE:
+		return kproto.Command_Security_ACL_DELETE, true
+	case kproto.Command_GETKEYRANGE, kproto.Command_MEDIASCAN, kproto.Command_MEDIAOPTIMIZE:
+		return kproto.Command_Security_ACL_RANGE, true
+	case kproto.Command_SETUP:
+		return kproto.Command_Security_ACL_SETUP, true
+	case kproto.Command_PEER2PEERPUSH:
+		return kproto.Command_Security_ACL_P2POP, true
+	case kproto.Command_GETLOG:
+		return kproto.Command_Security_ACL_GETLOG, true
+	case kproto.Command_SECURITY:
+		return kproto.Command_Security_ACL_SECURITY, true
+	case kproto.Command_SET_POWER_LEVEL:
+		return kproto.Command_Security_ACL_POWER_MANAGEMENT, true
+	}
+	return kproto.Command_Security_ACL_INVALID_PERMISSION, false
+}
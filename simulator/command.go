@@ -0,0 +1,612 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package simulator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"fmt"
+	"sort"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// batch holds PUT / DELETE commands received for one batch ID, they are only
+// applied when END_BATCH received.
+type batch struct {
+	cmds   []*kproto.Command
+	values [][]byte
+}
+
+func newStatus(code kproto.Command_Status_StatusCode, format string, args ...interface{}) *kproto.Command_Status {
+	s := &kproto.Command_Status{Code: code.Enum()}
+	if format != "" {
+		s.StatusMessage = proto.String(fmt.Sprintf(format, args...))
+	}
+	return s
+}
+
+func success() *kproto.Command_Status {
+	return newStatus(kproto.Command_Status_SUCCESS, "")
+}
+
+// responseType returns XXXXX_RESPONSE message type for request type t.
+func responseType(t kproto.Command_MessageType) kproto.Command_MessageType {
+	if _, ok := kproto.Command_MessageType_name[int32(t)-1]; ok && t > 0 && t%2 == 0 {
+		return t - 1
+	}
+	return kproto.Command_INVALID_MESSAGE_TYPE
+}
+
+// process handles one message received from client and sends back the response.
+// Returns false if the connection should be closed.
+func (s *Simulator) process(c *connection, msg *kproto.Message, value []byte) bool {
+	var identity int64
+	var hmacKey []byte
+
+	switch msg.GetAuthType() {
+	case kproto.Message_HMACAUTH:
+		identity = msg.GetHmacAuth().GetIdentity()
+		s.mu.Lock()
+		a, ok := s.acls[identity]
+		s.mu.Unlock()
+		if !ok || !hmac.Equal(computeHmac(msg.GetCommandBytes(), a.key), msg.GetHmacAuth().GetHmac()) {
+			c.terminate(kproto.Command_Status_HMAC_FAILURE, "HMAC verification failed for identity %d", identity)
+			return false
+		}
+		hmacKey = a.key
+	case kproto.Message_PINAUTH:
+		if !c.tls {
+			c.terminate(kproto.Command_Status_NOT_AUTHORIZED, "PIN operation requires TLS connection")
+			return false
+		}
+	default:
+		c.terminate(kproto.Command_Status_INVALID_REQUEST, "Unsupported message auth type %s", msg.GetAuthType().String())
+		return false
+	}
+
+	cmd := &kproto.Command{}
+	if err := proto.Unmarshal(msg.GetCommandBytes(), cmd); err != nil {
+		c.terminate(kproto.Command_Status_INVALID_REQUEST, "Can't parse command, %s", err.Error())
+		return false
+	}
+
+	t := cmd.GetHeader().GetMessageType()
+	resp := &kproto.Command{
+		Header: &kproto.Command_Header{
+			AckSequence:  proto.Int64(cmd.GetHeader().GetSequence()),
+			ConnectionID: proto.Int64(c.id),
+			MessageType:  responseType(t).Enum(),
+		},
+		Body: &kproto.Command_Body{},
+	}
+	var respValue []byte
+	var batched bool
+
+	s.mu.Lock()
+	status := s.check(c, msg, cmd)
+	if status == nil {
+		if cmd.GetHeader().BatchID != nil && (t == kproto.Command_PUT || t == kproto.Command_DELETE) {
+			status, batched = s.addToBatch(c, cmd, value)
+		} else {
+			respValue, status = s.execute(c, msg, cmd, value, resp)
+		}
+	}
+	s.count(t, len(value))
+	resp.Header.ClusterVersion = proto.Int64(s.clusterVersion)
+	s.mu.Unlock()
+
+	if batched {
+		// Batch PUT / DELETE have no response, status is reported by END_BATCH.
+		return true
+	}
+
+	resp.Status = status
+	return c.sendCommand(msg.GetAuthType(), identity, hmacKey, resp, respValue) == nil
+}
+
+// check verifies cluster version, lock state and permissions before command executed.
+func (s *Simulator) check(c *connection, msg *kproto.Message, cmd *kproto.Command) *kproto.Command_Status {
+	t := cmd.GetHeader().GetMessageType()
+
+	if cmd.GetHeader().GetClusterVersion() != s.clusterVersion {
+		return newStatus(kproto.Command_Status_VERSION_FAILURE, "Cluster version mismatch, expected %d", s.clusterVersion)
+	}
+
+	if (msg.GetAuthType() == kproto.Message_PINAUTH) != (t == kproto.Command_PINOP) {
+		return newStatus(kproto.Command_Status_INVALID_REQUEST, "PINOP must use PIN authentication")
+	}
+
+	if s.locked && t != kproto.Command_PINOP {
+		return newStatus(kproto.Command_Status_DEVICE_LOCKED, "Device is locked")
+	}
+
+	if msg.GetAuthType() == kproto.Message_HMACAUTH {
+		perm, required := requiredPermission(t)
+		if required {
+			a := s.acls[msg.GetHmacAuth().GetIdentity()]
+			var key []byte
+			if cmd.GetBody().GetKeyValue() != nil {
+				key = cmd.GetBody().GetKeyValue().GetKey()
+			}
+			if a == nil || !a.permitted(perm, key, c.tls) {
+				return newStatus(kproto.Command_Status_NOT_AUTHORIZED, "Permission %s denied", perm.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+// execute runs the command and fills the response body. Returns value to send back
+// with the response and the command status.
+func (s *Simulator) execute(c *connection, msg *kproto.Message, cmd *kproto.Command, value []byte, resp *kproto.Command) ([]byte, *kproto.Command_Status) {
+	switch cmd.GetHeader().GetMessageType() {
+	case kproto.Command_NOOP, kproto.Command_FLUSHALLDATA:
+		return nil, success()
+	case kproto.Command_GET, kproto.Command_GETNEXT, kproto.Command_GETPREVIOUS:
+		return s.get(cmd, resp)
+	case kproto.Command_GETVERSION:
+		return nil, s.getVersion(cmd, resp)
+	case kproto.Command_PUT:
+		return nil, s.put(cmd, value)
+	case kproto.Command_DELETE:
+		return nil, s.delete(cmd)
+	case kproto.Command_GETKEYRANGE:
+		return nil, s.getKeyRange(cmd, resp)
+	case kproto.Command_GETLOG:
+		return s.getLog(cmd, resp)
+	case kproto.Command_SETUP:
+		return nil, s.setup(cmd)
+	case kproto.Command_SECURITY:
+		return nil, s.security(c, cmd)
+	case kproto.Command_PINOP:
+		return nil, s.pinop(msg, cmd)
+	case kproto.Command_SET_POWER_LEVEL:
+		s.powerLevel = cmd.GetBody().GetPower().GetLevel()
+		return nil, success()
+	case kproto.Command_MEDIASCAN, kproto.Command_MEDIAOPTIMIZE:
+		return nil, success()
+	case kproto.Command_START_BATCH:
+		return nil, s.startBatch(c, cmd)
+	case kproto.Command_END_BATCH:
+		return nil, s.endBatch(c, cmd, resp)
+	case kproto.Command_ABORT_BATCH:
+		return nil, s.abortBatch(c, cmd)
+	}
+	return nil, newStatus(kproto.Command_Status_INVALID_REQUEST, "Unsupported message type %s",
+		cmd.GetHeader().GetMessageType().String())
+}
+
+func (s *Simulator) count(t kproto.Command_MessageType, n int) {
+	st, ok := s.stats[t]
+	if !ok {
+		st = &kproto.Command_GetLog_Statistics{
+			MessageType: t.Enum(),
+			Count:       proto.Uint64(0),
+			Bytes:       proto.Uint64(0),
+		}
+		s.stats[t] = st
+	}
+	*st.Count++
+	*st.Bytes += uint64(n)
+}
+
+// validate checks key value sizes against device limits.
+func (s *Simulator) validate(kv *kproto.Command_KeyValue, value []byte) *kproto.Command_Status {
+	switch {
+	case len(kv.GetKey()) > int(s.limits.GetMaxKeySize()):
+		return newStatus(kproto.Command_Status_INVALID_REQUEST, "Key too long")
+	case len(value) > int(s.limits.GetMaxValueSize()):
+		return newStatus(kproto.Command_Status_INVALID_REQUEST, "Value too long")
+	case len(kv.GetTag()) > int(s.limits.GetMaxTagSize()):
+		return newStatus(kproto.Command_Status_INVALID_REQUEST, "Tag too long")
+	case len(kv.GetDbVersion()) > int(s.limits.GetMaxVersionSize()),
+		len(kv.GetNewVersion()) > int(s.limits.GetMaxVersionSize()):
+		return newStatus(kproto.Command_Status_INVALID_REQUEST, "Version too long")
+	}
+	return nil
+}
+
+// checkVersion compares the version in request with version of current object,
+// unless the request is forced.
+func checkVersion(cur *entry, kv *kproto.Command_KeyValue) *kproto.Command_Status {
+	if kv.GetForce() {
+		return nil
+	}
+	var dbVersion []byte
+	if cur != nil {
+		dbVersion = cur.version
+	}
+	if !bytes.Equal(dbVersion, kv.GetDbVersion()) {
+		return newStatus(kproto.Command_Status_VERSION_MISMATCH, "Version mismatch")
+	}
+	return nil
+}
+
+func newEntry(kv *kproto.Command_KeyValue, value []byte) *entry {
+	return &entry{
+		key:     kv.GetKey(),
+		value:   value,
+		version: kv.GetNewVersion(),
+		tag:     kv.GetTag(),
+		algo:    kv.GetAlgorithm(),
+	}
+}
+
+func (s *Simulator) get(cmd *kproto.Command, resp *kproto.Command) ([]byte, *kproto.Command_Status) {
+	kv := cmd.GetBody().GetKeyValue()
+	if len(kv.GetKey()) > int(s.limits.GetMaxKeySize()) {
+		return nil, newStatus(kproto.Command_Status_INVALID_REQUEST, "Key too long")
+	}
+
+	var e *entry
+	switch cmd.GetHeader().GetMessageType() {
+	case kproto.Command_GET:
+		e = s.store.get(kv.GetKey())
+	case kproto.Command_GETNEXT:
+		e = s.store.next(kv.GetKey())
+	case kproto.Command_GETPREVIOUS:
+		e = s.store.previous(kv.GetKey())
+	}
+	if e == nil {
+		return nil, newStatus(kproto.Command_Status_NOT_FOUND, "Key not found")
+	}
+
+	resp.Body.KeyValue = &kproto.Command_KeyValue{
+		Key:       e.key,
+		DbVersion: e.version,
+		Tag:       e.tag,
+		Algorithm: e.algo.Enum(),
+	}
+	if kv.GetMetadataOnly() {
+		return nil, success()
+	}
+	return e.value, success()
+}
+
+func (s *Simulator) getVersion(cmd *kproto.Command, resp *kproto.Command) *kproto.Command_Status {
+	e := s.store.get(cmd.GetBody().GetKeyValue().GetKey())
+	if e == nil {
+		return newStatus(kproto.Command_Status_NOT_FOUND, "Key not found")
+	}
+	resp.Body.KeyValue = &kproto.Command_KeyValue{
+		DbVersion: e.version,
+	}
+	return success()
+}
+
+func (s *Simulator) put(cmd *kproto.Command, value []byte) *kproto.Command_Status {
+	kv := cmd.GetBody().GetKeyValue()
+	if st := s.validate(kv, value); st != nil {
+		return st
+	}
+	if st := checkVersion(s.store.get(kv.GetKey()), kv); st != nil {
+		return st
+	}
+	s.store.put(newEntry(kv, value))
+	return success()
+}
+
+func (s *Simulator) delete(cmd *kproto.Command) *kproto.Command_Status {
+	kv := cmd.GetBody().GetKeyValue()
+	if st := s.validate(kv, nil); st != nil {
+		return st
+	}
+	cur := s.store.get(kv.GetKey())
+	if cur == nil {
+		return newStatus(kproto.Command_Status_NOT_FOUND, "Key not found")
+	}
+	if st := checkVersion(cur, kv); st != nil {
+		return st
+	}
+	s.store.delete(kv.GetKey())
+	return success()
+}
+
+func (s *Simulator) getKeyRange(cmd *kproto.Command, resp *kproto.Command) *kproto.Command_Status {
+	r := cmd.GetBody().GetRange()
+	max := int(r.GetMaxReturned())
+	limit := int(s.limits.GetMaxKeyRangeCount())
+	if max > limit {
+		return newStatus(kproto.Command_Status_INVALID_REQUEST, "Max returned %d exceeds limit %d", max, limit)
+	}
+	if max <= 0 {
+		max = limit
+	}
+
+	resp.Body.Range = &kproto.Command_Range{
+		Keys: s.store.keyRange(r.GetStartKey(), r.GetEndKey(),
+			r.GetStartKeyInclusive(), r.GetEndKeyInclusive(), r.GetReverse(), max),
+	}
+	return success()
+}
+
+func (s *Simulator) configuration() *kproto.Command_GetLog_Configuration {
+	port := s.Port()
+	serial := s.op.SerialNumber
+	if serial == nil {
+		serial = []byte(fmt.Sprintf("SIM%08d", port))
+	}
+	wwn := s.op.WorldWideName
+	if wwn == nil {
+		wwn = []byte(fmt.Sprintf("5000c500%08x", port))
+	}
+	return &kproto.Command_GetLog_Configuration{
+		Vendor:          proto.String("Seagate"),
+		Model:           proto.String("Simulator"),
+		SerialNumber:    serial,
+		WorldWideName:   wwn,
+		Version:         proto.String("0.1.0"),
+		ProtocolVersion: proto.String("3.1.0"),
+		Interface: []*kproto.Command_GetLog_Configuration_Interface{
+			&kproto.Command_GetLog_Configuration_Interface{
+				Name:        proto.String("lo"),
+				Ipv4Address: []byte(s.op.Host),
+			},
+		},
+		Port:              proto.Int32(int32(port)),
+		TlsPort:           proto.Int32(int32(s.TLSPort())),
+		CurrentPowerLevel: s.powerLevel.Enum(),
+	}
+}
+
+func (s *Simulator) getLog(cmd *kproto.Command, resp *kproto.Command) ([]byte, *kproto.Command_Status) {
+	getlog := &kproto.Command_GetLog{
+		Types: cmd.GetBody().GetGetLog().GetTypes(),
+	}
+	resp.Body.GetLog = getlog
+
+	for _, t := range getlog.Types {
+		switch t {
+		case kproto.Command_GetLog_UTILIZATIONS:
+			for _, name := range []string{"HDA", "EN0", "EN1", "CPU"} {
+				getlog.Utilizations = append(getlog.Utilizations, &kproto.Command_GetLog_Utilization{
+					Name:  proto.String(name),
+					Value: proto.Float32(0.1),
+				})
+			}
+		case kproto.Command_GetLog_TEMPERATURES:
+			for _, name := range []string{"HDA", "CPU"} {
+				getlog.Temperatures = append(getlog.Temperatures, &kproto.Command_GetLog_Temperature{
+					Name:    proto.String(name),
+					Current: proto.Float32(35),
+					Minimum: proto.Float32(5),
+					Maximum: proto.Float32(100),
+					Target:  proto.Float32(25),
+				})
+			}
+		case kproto.Command_GetLog_CAPACITIES:
+			// One percent of capacity is reported as used by drive metadata.
+			used := s.op.Capacity/100 + s.store.bytes
+			getlog.Capacity = &kproto.Command_GetLog_Capacity{
+				NominalCapacityInBytes: proto.Uint64(s.op.Capacity),
+				PortionFull:            proto.Float32(float32(float64(used) / float64(s.op.Capacity))),
+			}
+		case kproto.Command_GetLog_CONFIGURATION:
+			getlog.Configuration = s.configuration()
+		case kproto.Command_GetLog_STATISTICS:
+			types := make([]int, 0, len(s.stats))
+			for t := range s.stats {
+				types = append(types, int(t))
+			}
+			sort.Ints(types)
+			for _, t := range types {
+				getlog.Statistics = append(getlog.Statistics,
+					proto.Clone(s.stats[kproto.Command_MessageType(t)]).(*kproto.Command_GetLog_Statistics))
+			}
+		case kproto.Command_GetLog_MESSAGES:
+			getlog.Messages = []byte("Kinetic simulator running")
+		case kproto.Command_GetLog_LIMITS:
+			getlog.Limits = s.limits
+		case kproto.Command_GetLog_DEVICE:
+			return nil, newStatus(kproto.Command_Status_NOT_FOUND, "Device log %s not found",
+				cmd.GetBody().GetGetLog().GetDevice().GetName())
+		default:
+			return nil, newStatus(kproto.Command_Status_INVALID_REQUEST, "Unsupported log type %s", t.String())
+		}
+	}
+	return nil, success()
+}
+
+func (s *Simulator) setup(cmd *kproto.Command) *kproto.Command_Status {
+	setup := cmd.GetBody().GetSetup()
+	if setup.NewClusterVersion != nil {
+		s.clusterVersion = setup.GetNewClusterVersion()
+	}
+	// Firmware download is accepted and discarded.
+	return success()
+}
+
+func (s *Simulator) security(c *connection, cmd *kproto.Command) *kproto.Command_Status {
+	if !c.tls {
+		return newStatus(kproto.Command_Status_NOT_AUTHORIZED, "Security operation requires TLS connection")
+	}
+
+	sec := cmd.GetBody().GetSecurity()
+	if len(sec.GetAcl()) > 0 {
+		if len(sec.GetAcl()) > int(s.limits.GetMaxIdentityCount()) {
+			return newStatus(kproto.Command_Status_INVALID_REQUEST, "Too many identities")
+		}
+		acls := make(map[int64]*acl)
+		for _, pacl := range sec.GetAcl() {
+			acls[pacl.GetIdentity()] = newACLFromProto(pacl)
+		}
+		s.acls = acls
+	}
+
+	if len(sec.GetNewLockPIN()) > int(s.limits.GetMaxPinSize()) ||
+		len(sec.GetNewErasePIN()) > int(s.limits.GetMaxPinSize()) {
+		return newStatus(kproto.Command_Status_INVALID_REQUEST, "PIN too long")
+	}
+	if sec.OldLockPIN != nil || sec.NewLockPIN != nil {
+		if !bytes.Equal(sec.GetOldLockPIN(), s.lockPin) {
+			return newStatus(kproto.Command_Status_NOT_AUTHORIZED, "Lock PIN mismatch")
+		}
+		s.lockPin = sec.GetNewLockPIN()
+	}
+	if sec.OldErasePIN != nil || sec.NewErasePIN != nil {
+		if !bytes.Equal(sec.GetOldErasePIN(), s.erasePin) {
+			return newStatus(kproto.Command_Status_NOT_AUTHORIZED, "Erase PIN mismatch")
+		}
+		s.erasePin = sec.GetNewErasePIN()
+	}
+	return success()
+}
+
+func (s *Simulator) pinop(msg *kproto.Message, cmd *kproto.Command) *kproto.Command_Status {
+	pin := msg.GetPinAuth().GetPin()
+	switch cmd.GetBody().GetPinOp().GetPinOpType() {
+	case kproto.Command_PinOperation_LOCK_PINOP:
+		if len(s.lockPin) == 0 {
+			return newStatus(kproto.Command_Status_INVALID_REQUEST, "Lock PIN not set")
+		}
+		if !bytes.Equal(pin, s.lockPin) {
+			return newStatus(kproto.Command_Status_NOT_AUTHORIZED, "Lock PIN mismatch")
+		}
+		s.locked = true
+	case kproto.Command_PinOperation_UNLOCK_PINOP:
+		if !bytes.Equal(pin, s.lockPin) {
+			return newStatus(kproto.Command_Status_NOT_AUTHORIZED, "Lock PIN mismatch")
+		}
+		if !s.locked {
+			return newStatus(kproto.Command_Status_DEVICE_ALREADY_UNLOCKED, "Device not locked")
+		}
+		s.locked = false
+	case kproto.Command_PinOperation_ERASE_PINOP, kproto.Command_PinOperation_SECURE_ERASE_PINOP:
+		if !bytes.Equal(pin, s.erasePin) {
+			return newStatus(kproto.Command_Status_NOT_AUTHORIZED, "Erase PIN mismatch")
+		}
+		s.store.clear()
+	default:
+		return newStatus(kproto.Command_Status_INVALID_REQUEST, "Unsupported PIN operation")
+	}
+	return success()
+}
+
+func (s *Simulator) startBatch(c *connection, cmd *kproto.Command) *kproto.Command_Status {
+	id := cmd.GetHeader().GetBatchID()
+	if _, ok := c.batches[id]; ok {
+		return newStatus(kproto.Command_Status_INVALID_BATCH, "Batch %d already started", id)
+	}
+	if s.batchCount >= int(s.limits.GetMaxBatchCountPerDevice()) {
+		return newStatus(kproto.Command_Status_INVALID_BATCH, "Too many open batches on device")
+	}
+	c.batches[id] = &batch{}
+	s.batchCount++
+	return success()
+}
+
+func (s *Simulator) addToBatch(c *connection, cmd *kproto.Command, value []byte) (*kproto.Command_Status, bool) {
+	id := cmd.GetHeader().GetBatchID()
+	b, ok := c.batches[id]
+	if !ok {
+		return newStatus(kproto.Command_Status_INVALID_BATCH, "Batch %d not started", id), false
+	}
+	b.cmds = append(b.cmds, cmd)
+	b.values = append(b.values, value)
+	return nil, true
+}
+
+func (s *Simulator) removeBatch(c *connection, id uint32) (*batch, bool) {
+	b, ok := c.batches[id]
+	if ok {
+		delete(c.batches, id)
+		s.batchCount--
+	}
+	return b, ok
+}
+
+// endBatch applies all batched commands atomically. Commands are verified in order against
+// the store with earlier commands of the batch applied, if any fails nothing is applied.
+func (s *Simulator) endBatch(c *connection, cmd *kproto.Command, resp *kproto.Command) *kproto.Command_Status {
+	id := cmd.GetHeader().GetBatchID()
+	b, ok := s.removeBatch(c, id)
+	if !ok {
+		return newStatus(kproto.Command_Status_INVALID_BATCH, "Batch %d not started", id)
+	}
+
+	resp.Body.Batch = &kproto.Command_Batch{}
+	if int(cmd.GetBody().GetBatch().GetCount()) != len(b.cmds) {
+		return newStatus(kproto.Command_Status_INVALID_BATCH, "Batch count %d mismatch, received %d operations",
+			cmd.GetBody().GetBatch().GetCount(), len(b.cmds))
+	}
+	if len(b.cmds) > int(s.limits.GetMaxOperationCountPerBatch()) {
+		return newStatus(kproto.Command_Status_INVALID_BATCH, "Too many operations in batch")
+	}
+
+	pending := make(map[string]*entry)
+	lookup := func(key []byte) *entry {
+		if e, ok := pending[string(key)]; ok {
+			return e
+		}
+		return s.store.get(key)
+	}
+
+	seqs := make([]int64, 0, len(b.cmds))
+	for k, bcmd := range b.cmds {
+		kv := bcmd.GetBody().GetKeyValue()
+		cur := lookup(kv.GetKey())
+
+		var st *kproto.Command_Status
+		if bcmd.GetHeader().GetMessageType() == kproto.Command_PUT {
+			st = s.validate(kv, b.values[k])
+			if st == nil {
+				st = checkVersion(cur, kv)
+			}
+			if st == nil {
+				pending[string(kv.GetKey())] = newEntry(kv, b.values[k])
+			}
+		} else {
+			st = s.validate(kv, nil)
+			if st == nil && cur == nil {
+				st = newStatus(kproto.Command_Status_NOT_FOUND, "Key not found")
+			}
+			if st == nil {
+				st = checkVersion(cur, kv)
+			}
+			if st == nil {
+				pending[string(kv.GetKey())] = nil
+			}
+		}
+		if st != nil {
+			resp.Body.Batch.FailedSequence = proto.Int64(bcmd.GetHeader().GetSequence())
+			return st
+		}
+		seqs = append(seqs, bcmd.GetHeader().GetSequence())
+	}
+
+	for key, e := range pending {
+		if e == nil {
+			s.store.delete([]byte(key))
+		} else {
+			s.store.put(e)
+		}
+	}
+	resp.Body.Batch.Sequence = seqs
+	return success()
+}
+
+func (s *Simulator) abortBatch(c *connection, cmd *kproto.Command) *kproto.Command_Status {
+	id := cmd.GetHeader().GetBatchID()
+	if _, ok := s.removeBatch(c, id); !ok {
+		return newStatus(kproto.Command_Status_INVALID_BATCH, "Batch %d not started", id)
+	}
+	return success()
+}
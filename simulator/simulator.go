@@ -0,0 +1,425 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+/*
+Package simulator implements an in-process kinetic device for hermetic tests.
+
+The simulator listens on a local port, speaks the same framing as a kinetic
+drive, performs the connection handshake and serves key value, range, log,
+setup, security and batch commands from an in-memory ordered store.
+*/
+package simulator
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// Default values used by simulator when not specified in Options.
+const (
+	DefaultHost     = "127.0.0.1"
+	DefaultIdentity = int64(1)
+	DefaultCapacity = uint64(4) * 1000 * 1000 * 1000 * 1000
+)
+
+// DefaultHmacKey is the HMAC key of DefaultIdentity, same as the kinetic-java simulator.
+var DefaultHmacKey = []byte("asdfasdf")
+
+// Options specify how to start a simulator.
+type Options struct {
+	Host           string                        // IP address to listen on, default is DefaultHost
+	Port           int                           // Plain port, 0 to pick a free port
+	TLSPort        int                           // TLS port, 0 to pick a free port. Only used if TLSConfig is set
+	TLSConfig      *tls.Config                   // TLS server configuration, TLS port is disabled if nil
+	Identities     map[int64][]byte              // HMAC key of each user identity, default is DefaultIdentity with DefaultHmacKey
+	ClusterVersion int64                         // Initial cluster version of the device
+	Capacity       uint64                        // Nominal capacity in bytes, default is DefaultCapacity
+	SerialNumber   []byte                        // Device serial number, generated from port if nil
+	WorldWideName  []byte                        // Device world wide name, generated from port if nil
+	Limits         *kproto.Command_GetLog_Limits // Device limits, default is DefaultLimits()
+}
+
+// Simulator is a kinetic device running inside the current process.
+type Simulator struct {
+	mu             sync.Mutex
+	op             Options
+	listener       net.Listener
+	tlsListener    net.Listener
+	store          store
+	acls           map[int64]*acl
+	limits         *kproto.Command_GetLog_Limits
+	clusterVersion int64
+	powerLevel     kproto.Command_PowerLevel
+	locked         bool
+	lockPin        []byte
+	erasePin       []byte
+	batchCount     int // Number of open batches on device
+	stats          map[kproto.Command_MessageType]*kproto.Command_GetLog_Statistics
+	conns          map[*connection]struct{}
+	closed         bool
+	wg             sync.WaitGroup
+}
+
+// connection is one client connection accepted by the simulator.
+type connection struct {
+	conn    net.Conn
+	tls     bool
+	id      int64
+	txMu    sync.Mutex
+	batches map[uint32]*batch
+}
+
+var (
+	connIDMu   sync.Mutex
+	lastConnID int64
+)
+
+// nextConnID returns a connection ID starting from current time in millisecond. IDs strictly
+// increase across all simulators in the process, so a restarted simulator never reuses one.
+func nextConnID() int64 {
+	connIDMu.Lock()
+	defer connIDMu.Unlock()
+	id := time.Now().UnixNano() / int64(time.Millisecond)
+	if id <= lastConnID {
+		id = lastConnID + 1
+	}
+	lastConnID = id
+	return id
+}
+
+// DefaultLimits returns the device limits reported when Options.Limits is nil.
+func DefaultLimits() *kproto.Command_GetLog_Limits {
+	return &kproto.Command_GetLog_Limits{
+		MaxKeySize:                  proto.Uint32(4096),
+		MaxValueSize:                proto.Uint32(1024 * 1024),
+		MaxVersionSize:              proto.Uint32(2048),
+		MaxTagSize:                  proto.Uint32(4096),
+		MaxConnections:              proto.Uint32(100),
+		MaxOutstandingReadRequests:  proto.Uint32(30),
+		MaxOutstandingWriteRequests: proto.Uint32(20),
+		MaxMessageSize:              proto.Uint32(4096 * 1024),
+		MaxKeyRangeCount:            proto.Uint32(200),
+		MaxIdentityCount:            proto.Uint32(100),
+		MaxPinSize:                  proto.Uint32(64),
+		MaxOperationCountPerBatch:   proto.Uint32(15),
+		MaxBatchCountPerDevice:      proto.Uint32(5),
+	}
+}
+
+// New starts a simulator listening on the address given by op.
+func New(op Options) (*Simulator, error) {
+	if op.Host == "" {
+		op.Host = DefaultHost
+	}
+	if op.Capacity == 0 {
+		op.Capacity = DefaultCapacity
+	}
+	if op.Identities == nil {
+		op.Identities = map[int64][]byte{DefaultIdentity: DefaultHmacKey}
+	}
+
+	s := &Simulator{
+		op:             op,
+		acls:           make(map[int64]*acl),
+		limits:         op.Limits,
+		clusterVersion: op.ClusterVersion,
+		powerLevel:     kproto.Command_OPERATIONAL,
+		stats:          make(map[kproto.Command_MessageType]*kproto.Command_GetLog_Statistics),
+		conns:          make(map[*connection]struct{}),
+	}
+	if s.limits == nil {
+		s.limits = DefaultLimits()
+	}
+	for id, key := range op.Identities {
+		s.acls[id] = newDefaultACL(key)
+	}
+
+	var err error
+	s.listener, err = net.Listen("tcp", net.JoinHostPort(op.Host, strconv.Itoa(op.Port)))
+	if err != nil {
+		return nil, err
+	}
+	if op.TLSConfig != nil {
+		s.tlsListener, err = tls.Listen("tcp", net.JoinHostPort(op.Host, strconv.Itoa(op.TLSPort)), op.TLSConfig)
+		if err != nil {
+			s.listener.Close()
+			return nil, err
+		}
+		s.wg.Add(1)
+		go s.accept(s.tlsListener, true)
+	}
+	s.wg.Add(1)
+	go s.accept(s.listener, false)
+
+	return s, nil
+}
+
+// Host returns the IP address simulator listens on.
+func (s *Simulator) Host() string {
+	return s.op.Host
+}
+
+// Port returns the plain port simulator listens on.
+func (s *Simulator) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// TLSPort returns the TLS port simulator listens on, or 0 if TLS is disabled.
+func (s *Simulator) TLSPort() int {
+	if s.tlsListener == nil {
+		return 0
+	}
+	return s.tlsListener.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops the simulator and drops all client connections.
+func (s *Simulator) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.listener.Close()
+	if s.tlsListener != nil {
+		s.tlsListener.Close()
+	}
+	for c := range s.conns {
+		c.conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// SendStatus sends an unsolicited status with code and message to all client connections, as device
+// does when it hibernates or shuts down. If terminate is true, connections are closed after status sent.
+func (s *Simulator) SendStatus(code kproto.Command_Status_StatusCode, message string, terminate bool) {
+	s.mu.Lock()
+	conns := make([]*connection, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.sendUnsolicited(&kproto.Command{
+			Status: &kproto.Command_Status{
+				Code:          code.Enum(),
+				StatusMessage: proto.String(message),
+			},
+		})
+		if terminate {
+			c.conn.Close()
+		}
+	}
+}
+
+func (s *Simulator) accept(l net.Listener, isTLS bool) {
+	defer s.wg.Done()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		c := &connection{
+			conn:    conn,
+			tls:     isTLS,
+			id:      nextConnID(),
+			batches: make(map[uint32]*batch),
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serve(c)
+	}
+}
+
+func (s *Simulator) serve(c *connection) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		s.batchCount -= len(c.batches)
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	if err := s.handshake(c); err != nil {
+		return
+	}
+
+	for {
+		msg, value, err := c.receive()
+		if err != nil {
+			return
+		}
+		if !s.process(c, msg, value) {
+			return
+		}
+	}
+}
+
+// handshake sends the unsolicited status carrying connection ID, cluster version,
+// device configuration and limits to a newly accepted client.
+func (s *Simulator) handshake(c *connection) error {
+	s.mu.Lock()
+	cmd := &kproto.Command{
+		Header: &kproto.Command_Header{
+			ConnectionID:   proto.Int64(c.id),
+			ClusterVersion: proto.Int64(s.clusterVersion),
+		},
+		Body: &kproto.Command_Body{
+			GetLog: &kproto.Command_GetLog{
+				Configuration: s.configuration(),
+				Limits:        s.limits,
+			},
+		},
+		Status: &kproto.Command_Status{
+			Code: kproto.Command_Status_SUCCESS.Enum(),
+		},
+	}
+	s.mu.Unlock()
+
+	return c.sendUnsolicited(cmd)
+}
+
+func computeHmac(data []byte, key []byte) []byte {
+	mac := hmac.New(sha1.New, key)
+
+	if data != nil && len(data) > 0 {
+		ln := make([]byte, 4)
+		binary.BigEndian.PutUint32(ln, uint32(len(data)))
+
+		mac.Write(ln)
+		mac.Write(data)
+	}
+
+	return mac.Sum(nil)
+}
+
+// receive reads one framed message and its value from client.
+func (c *connection) receive() (*kproto.Message, []byte, error) {
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, nil, err
+	}
+	if header[0] != 'F' {
+		return nil, nil, errors.New("Wrong magic number")
+	}
+
+	protoLen := int(binary.BigEndian.Uint32(header[1:5]))
+	valueLen := int(binary.BigEndian.Uint32(header[5:9]))
+
+	protoBuf := make([]byte, protoLen)
+	if _, err := io.ReadFull(c.conn, protoBuf); err != nil {
+		return nil, nil, err
+	}
+
+	var value []byte
+	if valueLen > 0 {
+		value = make([]byte, valueLen)
+		if _, err := io.ReadFull(c.conn, value); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	msg := &kproto.Message{}
+	if err := proto.Unmarshal(protoBuf, msg); err != nil {
+		return nil, nil, err
+	}
+
+	return msg, value, nil
+}
+
+// send writes one framed message and its value to client.
+func (c *connection) send(msg *kproto.Message, value []byte) error {
+	msgBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 9)
+	header[0] = 'F' // Magic number
+	binary.BigEndian.PutUint32(header[1:5], uint32(len(msgBytes)))
+	binary.BigEndian.PutUint32(header[5:9], uint32(len(value)))
+
+	packet := append(header, msgBytes...)
+	packet = append(packet, value...)
+
+	c.txMu.Lock()
+	_, err = c.conn.Write(packet)
+	c.txMu.Unlock()
+	return err
+}
+
+// sendCommand marshals cmd, signs it with key when the auth type is HMACAUTH and sends it.
+func (c *connection) sendCommand(t kproto.Message_AuthType, identity int64, key []byte, cmd *kproto.Command, value []byte) error {
+	cmdBytes, err := proto.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	msg := &kproto.Message{
+		AuthType:     t.Enum(),
+		CommandBytes: cmdBytes,
+	}
+	if t == kproto.Message_HMACAUTH {
+		msg.HmacAuth = &kproto.Message_HMACauth{
+			Identity: proto.Int64(identity),
+			Hmac:     computeHmac(cmdBytes, key),
+		}
+	}
+
+	return c.send(msg, value)
+}
+
+func (c *connection) sendUnsolicited(cmd *kproto.Command) error {
+	return c.sendCommand(kproto.Message_UNSOLICITEDSTATUS, 0, nil, cmd, nil)
+}
+
+// terminate sends an unsolicited status with code and message, the caller is expected
+// to close the connection afterwards.
+func (c *connection) terminate(code kproto.Command_Status_StatusCode, format string, args ...interface{}) {
+	cmd := &kproto.Command{
+		Status: &kproto.Command_Status{
+			Code:          code.Enum(),
+			StatusMessage: proto.String(fmt.Sprintf(format, args...)),
+		},
+	}
+	c.sendUnsolicited(cmd)
+}
@@ -0,0 +1,131 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package simulator
+
+import (
+	"bytes"
+	"sort"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// entry is one object held by the simulated drive.
+type entry struct {
+	key     []byte
+	value   []byte
+	version []byte
+	tag     []byte
+	algo    kproto.Command_Algorithm
+}
+
+// store keeps objects ordered by key, so the range style commands
+// (GETNEXT, GETPREVIOUS, GETKEYRANGE) can be served by binary search.
+// store is not safe for concurrent use, Simulator serializes access.
+type store struct {
+	entries []*entry
+	bytes   uint64 // Sum of key and value length of all objects
+}
+
+// search returns the index of the first entry with key >= key.
+func (s *store) search(key []byte) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		return bytes.Compare(s.entries[i].key, key) >= 0
+	})
+}
+
+func (s *store) get(key []byte) *entry {
+	i := s.search(key)
+	if i < len(s.entries) && bytes.Equal(s.entries[i].key, key) {
+		return s.entries[i]
+	}
+	return nil
+}
+
+func (s *store) put(e *entry) {
+	i := s.search(e.key)
+	if i < len(s.entries) && bytes.Equal(s.entries[i].key, e.key) {
+		s.bytes -= uint64(len(s.entries[i].key) + len(s.entries[i].value))
+		s.entries[i] = e
+	} else {
+		s.entries = append(s.entries, nil)
+		copy(s.entries[i+1:], s.entries[i:])
+		s.entries[i] = e
+	}
+	s.bytes += uint64(len(e.key) + len(e.value))
+}
+
+func (s *store) delete(key []byte) bool {
+	i := s.search(key)
+	if i < len(s.entries) && bytes.Equal(s.entries[i].key, key) {
+		s.bytes -= uint64(len(s.entries[i].key) + len(s.entries[i].value))
+		s.entries = append(s.entries[:i], s.entries[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// next returns the first entry with key strictly after key.
+func (s *store) next(key []byte) *entry {
+	i := s.search(key)
+	if i < len(s.entries) && bytes.Equal(s.entries[i].key, key) {
+		i++
+	}
+	if i < len(s.entries) {
+		return s.entries[i]
+	}
+	return nil
+}
+
+// previous returns the last entry with key strictly before key.
+func (s *store) previous(key []byte) *entry {
+	i := s.search(key)
+	if i > 0 {
+		return s.entries[i-1]
+	}
+	return nil
+}
+
+// keyRange returns at most max keys between start and end, honoring inclusiveness.
+// When reverse is true, keys are returned in descending order starting from end.
+func (s *store) keyRange(start, end []byte, startInclusive, endInclusive, reverse bool, max int) [][]byte {
+	lo := s.search(start)
+	if !startInclusive && lo < len(s.entries) && bytes.Equal(s.entries[lo].key, start) {
+		lo++
+	}
+	hi := s.search(end)
+	if endInclusive && hi < len(s.entries) && bytes.Equal(s.entries[hi].key, end) {
+		hi++
+	}
+
+	keys := make([][]byte, 0)
+	if reverse {
+		for i := hi - 1; i >= lo && len(keys) < max; i-- {
+			keys = append(keys, s.entries[i].key)
+		}
+	} else {
+		for i := lo; i < hi && len(keys) < max; i++ {
+			keys = append(keys, s.entries[i].key)
+		}
+	}
+	return keys
+}
+
+func (s *store) clear() {
+	s.entries = nil
+	s.bytes = 0
+}
@@ -0,0 +1,80 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package simulator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newTestStore(keys ...string) *store {
+	s := &store{}
+	for _, k := range keys {
+		s.put(&entry{key: []byte(k), value: []byte("v-" + k)})
+	}
+	return s
+}
+
+func TestStoreOrder(t *testing.T) {
+	s := newTestStore("c", "a", "b", "a")
+	if len(s.entries) != 3 {
+		t.Fatalf("Expect 3 entries, got %d", len(s.entries))
+	}
+	for k, want := range []string{"a", "b", "c"} {
+		if string(s.entries[k].key) != want {
+			t.Fatalf("entries[%d] = %s, expect %s", k, s.entries[k].key, want)
+		}
+	}
+	if s.next([]byte("a")) == nil || string(s.next([]byte("a")).key) != "b" {
+		t.Fatal("next of a should be b")
+	}
+	if s.previous([]byte("a")) != nil {
+		t.Fatal("previous of a should not exist")
+	}
+	if !s.delete([]byte("b")) || s.get([]byte("b")) != nil {
+		t.Fatal("delete b failure")
+	}
+}
+
+func TestStoreKeyRange(t *testing.T) {
+	s := newTestStore("a", "b", "c", "d", "e")
+	tests := []struct {
+		start, end       string
+		startInc, endInc bool
+		reverse          bool
+		max              int
+		expect           []string
+	}{
+		{"a", "e", true, true, false, 10, []string{"a", "b", "c", "d", "e"}},
+		{"a", "e", false, false, false, 10, []string{"b", "c", "d"}},
+		{"a", "e", true, true, false, 2, []string{"a", "b"}},
+		{"a", "e", true, true, true, 2, []string{"e", "d"}},
+		{"b", "d", false, true, true, 10, []string{"d", "c"}},
+	}
+	for _, test := range tests {
+		keys := s.keyRange([]byte(test.start), []byte(test.end), test.startInc, test.endInc, test.reverse, test.max)
+		if len(keys) != len(test.expect) {
+			t.Fatalf("%+v: got %q", test, keys)
+		}
+		for k := range keys {
+			if !bytes.Equal(keys[k], []byte(test.expect[k])) {
+				t.Fatalf("%+v: got %q", test, keys)
+			}
+		}
+	}
+}
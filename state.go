@@ -0,0 +1,133 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"errors"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+// ConnectionState is the state of connection to kinetic device.
+type ConnectionState int32
+
+// Connection states
+// StateConnecting: Connection is being established, or being reconnected after connection lost.
+// StateReady: Connection is ready for requests.
+// StateHibernating: Device announced it is going to hibernate, requests may not be served until device wakes up.
+// StateTerminated: Connection is closed by client, or device announced termination by unsolicited status
+// and reconnect is not enabled.
+// StateFailed: Connection failed by network error, or reconnect gave up.
+const (
+	_                ConnectionState = iota
+	StateConnecting  ConnectionState = iota
+	StateReady       ConnectionState = iota
+	StateHibernating ConnectionState = iota
+	StateTerminated  ConnectionState = iota
+	StateFailed      ConnectionState = iota
+)
+
+var strConnectionState = map[ConnectionState]string{
+	StateConnecting:  "CONNECTING",
+	StateReady:       "READY",
+	StateHibernating: "HIBERNATING",
+	StateTerminated:  "TERMINATED",
+	StateFailed:      "FAILED",
+}
+
+func (s ConnectionState) String() string {
+	str, ok := strConnectionState[s]
+	if ok {
+		return str
+	}
+	return "Unknown ConnectionState"
+}
+
+// StatusEvent is an unsolicited status received from kinetic device.
+type StatusEvent struct {
+	Status Status          // Status announced by device
+	State  ConnectionState // Connection state after the status handled
+}
+
+// state returns current connection state. Caller must hold stateMu.
+func (ns *networkService) state() ConnectionState {
+	switch {
+	case ns.terminated:
+		return StateTerminated
+	case ns.fatal:
+		return StateFailed
+	case ns.connID < 0 || ns.reconnecting != nil:
+		return StateConnecting
+	case ns.hibernating:
+		return StateHibernating
+	default:
+		return StateReady
+	}
+}
+
+// State returns current connection state.
+func (ns *networkService) State() ConnectionState {
+	ns.stateMu.Lock()
+	defer ns.stateMu.Unlock()
+	return ns.state()
+}
+
+// unsolicited handles UNSOLICITEDSTATUS received after handshake. HIBERNATE moves connection to
+// StateHibernating until next response received. Any other status means device is terminating the
+// connection, all requests in queue fail with the status. If reconnect enabled, reconnect starts,
+// otherwise network service is marked as fatal.
+func (ns *networkService) unsolicited(cmd *kproto.Command) {
+	s := getStatusFromProto(cmd)
+	klog.Warnf("Unsolicited status from %s : %s", ns.option.Host, s.String())
+
+	ns.stateMu.Lock()
+	terminate := s.Code != RemoteHibernate && !ns.fatal
+	reconnect := terminate && ns.reconnect != nil
+	if s.Code == RemoteHibernate {
+		ns.hibernating = true
+	} else if terminate && !reconnect {
+		ns.terminated = true
+		ns.fatal = true
+		ns.fatalError = errors.New("Connection terminated by device, " + s.String())
+	}
+	ns.stateMu.Unlock()
+
+	if terminate {
+		ns.clientError(s, nil)
+	}
+	if reconnect {
+		ns.startReconnect(errors.New("Connection terminated by device, " + s.String()))
+	}
+
+	if ns.option.StatusEvents != nil {
+		select {
+		case ns.option.StatusEvents <- StatusEvent{Status: s, State: ns.State()}:
+		default:
+		}
+	}
+}
+
+// State returns current state of connection to kinetic device.
+func (conn *NonBlockConnection) State() ConnectionState {
+	return conn.service.State()
+}
+
+// State returns current state of connection to kinetic device.
+func (conn *BlockConnection) State() ConnectionState {
+	return conn.nbc.State()
+}
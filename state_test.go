@@ -0,0 +1,131 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"testing"
+	"time"
+
+	kproto "github.com/Kinetic/kinetic-go/proto"
+)
+
+func waitStatusEvent(t *testing.T, events <-chan StatusEvent) StatusEvent {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("StatusEvent not received")
+	}
+	return StatusEvent{}
+}
+
+func TestConnectionState(t *testing.T) {
+	sims, ops := startSimulators(t, 1)
+	defer sims[0].Close()
+
+	// StatusEvents implies ReceiveLoop, state changes without pending requests
+	events := make(chan StatusEvent, 4)
+	op := ops[0]
+	op.StatusEvents = events
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	if conn.State() != StateReady {
+		t.Fatal("Connection state expect READY, actual", conn.State().String())
+	}
+
+	sims[0].SendStatus(kproto.Command_Status_HIBERNATE, "hibernate", false)
+	e := waitStatusEvent(t, events)
+	if e.Status.Code != RemoteHibernate || e.State != StateHibernating || conn.State() != StateHibernating {
+		t.Fatal("Hibernate StatusEvent Failure", e.Status.String(), e.State.String(), conn.State().String())
+	}
+	if status, err := conn.NoOp(); err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp Failure", err, status.String())
+	}
+	if conn.State() != StateReady {
+		t.Fatal("Connection state expect READY after response, actual", conn.State().String())
+	}
+
+	// Pending request fails with status announced by device
+	callback := &GenericCallback{}
+	h := NewResponseHandler(callback)
+	ns := conn.nbc.service
+	ns.mapMu.Lock()
+	ns.hmap[-2] = h
+	ns.mapMu.Unlock()
+
+	sims[0].SendStatus(kproto.Command_Status_SHUTDOWN, "shutdown", true)
+	e = waitStatusEvent(t, events)
+	if e.Status.Code != RemoteShutdown || e.State != StateTerminated {
+		t.Fatal("Shutdown StatusEvent Failure", e.Status.String(), e.State.String())
+	}
+	h.wait()
+	if callback.Status().Code != RemoteShutdown {
+		t.Fatal("Pending request expect REMOTE_SHUTDOWN, actual", callback.Status().String())
+	}
+	if conn.State() != StateTerminated {
+		t.Fatal("Connection state expect TERMINATED, actual", conn.State().String())
+	}
+	if _, err := conn.NoOp(); err == nil {
+		t.Fatal("NoOp on terminated connection expect error")
+	}
+}
+
+func TestConnectionStateReconnect(t *testing.T) {
+	sims, ops := startSimulators(t, 1)
+	defer sims[0].Close()
+
+	events := make(chan StatusEvent, 4)
+	reconnects := make(chan ReconnectEvent, 16)
+	op := ops[0]
+	op.StatusEvents = events
+	op.Reconnect = &ReconnectOptions{MaxAttempts: 3, Interval: 10, Events: reconnects}
+	conn, err := NewBlockConnection(op)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	callback := &GenericCallback{}
+	h := NewResponseHandler(callback)
+	ns := conn.nbc.service
+	ns.mapMu.Lock()
+	ns.hmap[-2] = h
+	ns.mapMu.Unlock()
+
+	// Device terminated connection, pending request fails and connection reconnects
+	sims[0].SendStatus(kproto.Command_Status_SHUTDOWN, "shutdown", true)
+	e := waitStatusEvent(t, events)
+	if e.Status.Code != RemoteShutdown || e.State == StateTerminated || e.State == StateFailed {
+		t.Fatal("Shutdown StatusEvent Failure", e.Status.String(), e.State.String())
+	}
+	h.wait()
+	if callback.Status().Code != RemoteShutdown {
+		t.Fatal("Pending request expect REMOTE_SHUTDOWN, actual", callback.Status().String())
+	}
+	waitReconnectEvent(t, reconnects, ReconnectSucceeded)
+	if status, err := conn.NoOp(); err != nil || status.Code != OK {
+		t.Fatal("Blocking NoOp after reconnect Failure", err, status.String())
+	}
+	if conn.State() != StateReady {
+		t.Fatal("Connection state expect READY after reconnect, actual", conn.State().String())
+	}
+}
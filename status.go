@@ -18,6 +18,7 @@
 package kinetic
 
 import (
+	"fmt"
 	"strconv"
 
 	kproto "github.com/Kinetic/kinetic-go/proto"
@@ -61,6 +62,9 @@ const (
 	RemoteExecuteComplete              StatusCode = iota
 	RemoteHibernate                    StatusCode = iota
 	RemoteShutdown                     StatusCode = iota
+	ClientRequestCanceled              StatusCode = iota
+	ClientRequestTimeout               StatusCode = iota
+	ClientIntegrityError               StatusCode = iota
 )
 
 var statusName = map[StatusCode]string{
@@ -96,6 +100,9 @@ var statusName = map[StatusCode]string{
 	RemoteExecuteComplete:              "REMOTE_EXECUTE_COMPLETE",
 	RemoteHibernate:                    "REMOTE_HIBERNATE",
 	RemoteShutdown:                     "REMOTE_SHUTDOWN",
+	ClientRequestCanceled:              "CLIENT_REQUEST_CANCELED",
+	ClientRequestTimeout:               "CLIENT_REQUEST_TIMEOUT",
+	ClientIntegrityError:               "CLIENT_INTEGRITY_ERROR",
 }
 
 // String returns string value of StatusCode.
@@ -133,6 +140,33 @@ func (s Status) String() string {
 	return ret
 }
 
+// VersionMismatchError is returned by BlockConnection Put and Delete when
+// the object version on kinetic device doesn't match the expected Record.Version.
+type VersionMismatchError struct {
+	Key     []byte // Key of the object
+	Version []byte // Version expected by client
+	Status  Status // Status returned from kinetic device
+}
+
+// Error returns the detail message of version mismatch.
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("Version mismatch for key %q, expected version %x : %s", e.Key, e.Version, e.Status.ErrorMsg)
+}
+
+// IsVersionMismatch returns true if err is a VersionMismatchError,
+// or a Status with code RemoteVersionMismatch.
+func IsVersionMismatch(err error) bool {
+	switch e := err.(type) {
+	case *VersionMismatchError:
+		return true
+	case Status:
+		return e.Code == RemoteVersionMismatch
+	case *Status:
+		return e != nil && e.Code == RemoteVersionMismatch
+	}
+	return false
+}
+
 func convertStatusCodeToProto(s StatusCode) kproto.Command_Status_StatusCode {
 	ret := kproto.Command_Status_INVALID_STATUS_CODE
 	switch s {
@@ -0,0 +1,171 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"strings"
+)
+
+// TLSOptions specify how TLS connection to kinetic device is established and verified.
+// Without TLSOptions, TLS connection doesn't verify device certificate.
+type TLSOptions struct {
+	// Complete TLS configuration. If set, CAFile, CertFile, KeyFile, ServerName and Insecure are ignored.
+	Config *tls.Config
+	// PEM file of CA certificates to verify device certificate, system CA certificates if empty.
+	CAFile string
+	// PEM files of client certificate and its private key, presented to device if set.
+	CertFile string
+	KeyFile  string
+	// Name expected in device certificate, ClientOptions.Host if empty.
+	ServerName string
+	// SHA-256 fingerprints of pinned device certificates, device certificate must match one of them.
+	// If CAFile is empty, certificate chain is not verified, the pinned fingerprint is trusted instead.
+	Fingerprints [][]byte
+	// Skip verifying device certificate chain and name. Pinned fingerprints are still checked.
+	Insecure bool
+	// Verify device certificate against device serial number or world wide name from handshake.
+	VerifyDevice bool
+}
+
+// tlsConfig builds TLS configuration from op, for connection to host.
+func (op *TLSOptions) tlsConfig(host string) (*tls.Config, error) {
+	if op == nil {
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+	if op.Config != nil {
+		return op.Config, nil
+	}
+
+	config := &tls.Config{
+		ServerName:         op.ServerName,
+		InsecureSkipVerify: op.Insecure || (op.CAFile == "" && len(op.Fingerprints) > 0),
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+
+	if op.CAFile != "" {
+		pem, err := ioutil.ReadFile(op.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No CA certificate found in " + op.CAFile)
+		}
+	}
+
+	if op.CertFile != "" || op.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(op.CertFile, op.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// verifyPinned checks device certificate of conn matches one of the pinned fingerprints.
+func (op *TLSOptions) verifyPinned(conn *tls.Conn) error {
+	if op == nil || len(op.Fingerprints) == 0 {
+		return nil
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return errors.New("Device presents no certificate")
+	}
+	fp := CertificateFingerprint(certs[0])
+	for _, pinned := range op.Fingerprints {
+		if bytes.Equal(fp, pinned) {
+			return nil
+		}
+	}
+	return errors.New("Device certificate not pinned, fingerprint " + hex.EncodeToString(fp))
+}
+
+// CertificateFingerprint returns SHA-256 fingerprint of cert, to be pinned in TLSOptions.Fingerprints.
+func CertificateFingerprint(cert *x509.Certificate) []byte {
+	fp := sha256.Sum256(cert.Raw)
+	return fp[:]
+}
+
+// VerifyDeviceCertificate verifies cert is issued to the kinetic device described by conf, the serial
+// number or world wide name of device must be the subject serial number, common name, organizational
+// unit or a DNS name of cert. World wide name is also matched in hex.
+func VerifyDeviceCertificate(cert *x509.Certificate, conf *ConfigurationLog) error {
+	ids := make([]string, 0, 3)
+	if len(conf.SerialNumber) > 0 {
+		ids = append(ids, string(conf.SerialNumber))
+	}
+	if len(conf.WorldWideName) > 0 {
+		ids = append(ids, string(conf.WorldWideName), hex.EncodeToString(conf.WorldWideName))
+	}
+	if len(ids) == 0 {
+		return errors.New("Device reports no serial number or world wide name")
+	}
+
+	names := append([]string{cert.Subject.SerialNumber, cert.Subject.CommonName}, cert.Subject.OrganizationalUnit...)
+	names = append(names, cert.DNSNames...)
+	for _, name := range names {
+		for _, id := range ids {
+			if name != "" && strings.EqualFold(name, id) {
+				return nil
+			}
+		}
+	}
+	return errors.New("Device certificate not issued to device " + ids[0])
+}
+
+// verifyDevice verifies certificate of TLS connection against device information from handshake.
+func (ns *networkService) verifyDevice() error {
+	ns.stateMu.Lock()
+	conn, ok := ns.conn.(*tls.Conn)
+	conf := ns.device.Configuration
+	ns.stateMu.Unlock()
+	if !ok {
+		return errors.New("Connection is not TLS connection")
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return errors.New("Device presents no certificate")
+	}
+	if conf == nil {
+		return errors.New("Device reports no configuration")
+	}
+	return VerifyDeviceCertificate(certs[0], conf)
+}
+
+// VerifyDevice verifies certificate of TLS connection is issued to the connected device,
+// by VerifyDeviceCertificate with device configuration from handshake.
+func (conn *NonBlockConnection) VerifyDevice() error {
+	return conn.service.verifyDevice()
+}
+
+// VerifyDevice verifies certificate of TLS connection is issued to the connected device,
+// by VerifyDeviceCertificate with device configuration from handshake.
+func (conn *BlockConnection) VerifyDevice() error {
+	return conn.nbc.VerifyDevice()
+}
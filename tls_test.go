@@ -0,0 +1,181 @@
+/**
+ * Copyright 2013-2016 Seagate Technology LLC.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla
+ * Public License, v. 2.0. If a copy of the MPL was not
+ * distributed with this file, You can obtain one at
+ * https://mozilla.org/MP:/2.0/.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but is provided AS-IS, WITHOUT ANY WARRANTY; including without
+ * the implied warranty of MERCHANTABILITY, NON-INFRINGEMENT or
+ * FITNESS FOR A PARTICULAR PURPOSE. See the Mozilla Public
+ * License for more details.
+ *
+ * See www.openkinetic.org for more project information
+ */
+
+package kinetic
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Kinetic/kinetic-go/simulator"
+)
+
+// testCert is a certificate with its private key, issued by parent or self-signed.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, tmpl *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func newTestCA(t *testing.T, name string) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+// writePEM writes certificate and private key to dir as name.crt and name.key.
+func (c *testCert) writePEM(t *testing.T, dir string, name string) (string, string) {
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return certFile, keyFile
+}
+
+func TestTLSOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kinetic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, "Kinetic CA")
+	other := newTestCA(t, "Other CA")
+	device := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "kinetic", SerialNumber: "SIMSERIAL"},
+		IPAddresses:  []net.IP{net.ParseIP(option.Host)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "client"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	caFile, _ := ca.writePEM(t, dir, "ca")
+	otherFile, _ := other.writePEM(t, dir, "other")
+	certFile, keyFile := client.writePEM(t, dir, "client")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	sim, err := simulator.New(simulator.Options{
+		Host:         option.Host,
+		SerialNumber: []byte("SIMSERIAL"),
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{device.der}, PrivateKey: device.key}},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+		},
+	})
+	if err != nil {
+		t.Fatal("Simulator start failure", err)
+	}
+	defer sim.Close()
+
+	connect := func(op *TLSOptions) error {
+		o := option
+		o.Port = sim.TLSPort()
+		o.UseSSL = true
+		o.TLS = op
+		conn, err := NewBlockConnection(o)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if status, err := conn.NoOp(); err != nil || status.Code != OK {
+			t.Fatal("Blocking NoOp Failure", err, status.String())
+		}
+		return conn.VerifyDevice()
+	}
+
+	if err = connect(&TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, VerifyDevice: true}); err != nil {
+		t.Fatal("TLS connection with CA and client certificate Failure", err)
+	}
+	if err = connect(&TLSOptions{CAFile: caFile}); err == nil {
+		t.Fatal("TLS connection without client certificate expect failure")
+	}
+	if err = connect(&TLSOptions{CAFile: otherFile, CertFile: certFile, KeyFile: keyFile}); err == nil {
+		t.Fatal("TLS connection with wrong CA expect failure")
+	}
+	// ServerName of complete Config defaults to ClientOptions.Host
+	cert := tls.Certificate{Certificate: [][]byte{client.der}, PrivateKey: client.key}
+	if err = connect(&TLSOptions{Config: &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}}}); err != nil {
+		t.Fatal("TLS connection with Config without ServerName Failure", err)
+	}
+	pinned := [][]byte{CertificateFingerprint(device.cert)}
+	if err = connect(&TLSOptions{Fingerprints: pinned, CertFile: certFile, KeyFile: keyFile}); err != nil {
+		t.Fatal("TLS connection with pinned certificate Failure", err)
+	}
+	pinned = [][]byte{CertificateFingerprint(ca.cert)}
+	if err = connect(&TLSOptions{Fingerprints: pinned, CertFile: certFile, KeyFile: keyFile}); err == nil {
+		t.Fatal("TLS connection with wrong pinned certificate expect failure")
+	}
+
+	conf := &ConfigurationLog{SerialNumber: []byte("OTHER"), WorldWideName: []byte{0x50, 0x00, 0xc5}}
+	if err = VerifyDeviceCertificate(device.cert, conf); err == nil {
+		t.Fatal("VerifyDeviceCertificate with other device expect failure")
+	}
+	device.cert.DNSNames = []string{"5000C5"}
+	if err = VerifyDeviceCertificate(device.cert, conf); err != nil {
+		t.Fatal("VerifyDeviceCertificate by world wide name Failure", err)
+	}
+}
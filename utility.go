@@ -20,10 +20,14 @@ package kinetic
 import (
 	//"fmt"
 	//"io"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 // UpdateFirmware is the utility function to update drive firmware.
@@ -57,6 +61,55 @@ func UpdateFirmware(conn *BlockConnection, file string) error {
 // Input number of keys should equal to total number of object files on drive.
 // If any chunk PUT fail, upload will stop and return status.
 func UploadFile(conn *BlockConnection, file string, keys [][]byte, chunkSize int32) ([]Status, error) {
+	f, err := openUpload(file, keys, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, chunkSize)
+	var n int
+	var offset, cnt int = 0, 0
+
+	status := make([]Status, 0)
+
+	for {
+		n, err = f.Read(buf)
+		if err != nil {
+			if err == io.EOF {
+				break
+			} else {
+				// TODO: Should delete already PUT objects???
+				return nil, err
+			}
+		}
+
+		tag, _ := ComputeTag(AlgorithmSHA1, buf[:n])
+		entry := Record{
+			Key:   keys[cnt],
+			Value: buf[:n],
+			Tag:   tag,
+			Sync:  SyncWriteThrough,
+			Algo:  AlgorithmSHA1,
+			Force: true,
+		}
+		sts, err := conn.Put(&entry)
+		status = append(status, sts)
+		if err != nil || sts.Code != OK {
+			klog.Errorf("Upload fail for chunk[%02d], key[%s] : %s\n", cnt, keys[cnt], sts.Error())
+			// TODO: Should delete already PUT objects???
+			return status, err
+		}
+
+		offset += n
+		cnt++
+	}
+
+	return status, nil
+}
+
+// openUpload checks file can be uploaded as len(keys) chunks of chunkSize, and opens it.
+func openUpload(file string, keys [][]byte, chunkSize int32) (*os.File, error) {
 	info, err := os.Stat(file)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -83,48 +136,397 @@ func UploadFile(conn *BlockConnection, file string, keys [][]byte, chunkSize int
 		return nil, fmt.Errorf("Expect %d keys, actual %d keys", chunks, len(keys))
 	}
 
-	f, err := os.Open(file)
+	return os.Open(file)
+}
+
+// DefaultUploadOutstanding is default max outstanding PUT requests of UploadFileNonBlock,
+// if kinetic device doesn't report MaxOutstandingWriteRequests limit.
+const DefaultUploadOutstanding = 16
+
+// UploadOptions specify how UploadFileNonBlock writes chunks.
+type UploadOptions struct {
+	Outstanding int  // Max outstanding PUT requests, 0 to use device MaxOutstandingWriteRequests limit
+	WriteBack   bool // PUT chunks with SyncWriteBack, and FLUSH after all chunks PUT
+}
+
+// UploadFileNonBlock is the utility function to upload file to drive, same as UploadFile except
+// chunks are PUT by pipelined requests on NonBlockConnection, up to UploadOptions.Outstanding at the same time.
+// If any chunk PUT fail, no more chunk will be PUT, and the Status of chunks already PUT are returned
+// together with the error. The failed chunk Status is returned as error if PUT is not OK.
+func UploadFileNonBlock(conn *NonBlockConnection, file string, keys [][]byte, chunkSize int32, op UploadOptions) ([]Status, error) {
+	f, err := openUpload(file, keys, chunkSize)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	buf := make([]byte, chunkSize)
-	var n int
-	var offset, cnt int = 0, 0
+	max := op.Outstanding
+	if max <= 0 {
+		max = DefaultUploadOutstanding
+		if limits := conn.service.limits(); limits != nil && limits.MaxOutstandingWriteRequests > 0 {
+			max = int(limits.MaxOutstandingWriteRequests)
+		}
+	}
+	sync := SyncWriteThrough
+	if op.WriteBack {
+		sync = SyncWriteBack
+	}
 
-	status := make([]Status, 0)
+	status := make([]Status, 0, len(keys))
+	window := make([]*StatusFuture, 0, max)
+	var failed error
 
-	for {
-		n, err = f.Read(buf)
-		if err != nil {
-			if err == io.EOF {
+	// wait waits for the oldest outstanding PUT.
+	wait := func() {
+		sts, err := window[0].Result()
+		window = window[1:]
+		cnt := len(status)
+		status = append(status, sts)
+		if err == nil && sts.Code != OK {
+			err = sts
+		}
+		if err != nil && failed == nil {
+			klog.Errorf("Upload fail for chunk[%02d], key[%s] : %s\n", cnt, keys[cnt], err.Error())
+			failed = err
+		}
+	}
+
+	for cnt := 0; cnt < len(keys) && failed == nil; cnt++ {
+		if len(window) == max {
+			wait()
+			if failed != nil {
 				break
-			} else {
-				// TODO: Should delete already PUT objects???
-				return nil, err
 			}
 		}
 
+		buf := make([]byte, chunkSize)
+		n, err := io.ReadFull(f, buf)
+		if err == io.ErrUnexpectedEOF && cnt == len(keys)-1 {
+			err = nil
+		}
+		if err != nil {
+			failed = err
+			break
+		}
+
+		tag, _ := ComputeTag(AlgorithmSHA1, buf[:n])
 		entry := Record{
 			Key:   keys[cnt],
 			Value: buf[:n],
-			Tag:   []byte(""),
-			Sync:  SyncWriteThrough,
+			Tag:   tag,
+			Sync:  sync,
 			Algo:  AlgorithmSHA1,
 			Force: true,
 		}
-		sts, err := conn.Put(&entry)
-		status = append(status, sts)
-		if err != nil || sts.Code != OK {
-			klog.Errorf("Upload fail for chunk[%02d], key[%s] : %s\n", cnt, keys[cnt], sts.Error())
-			// TODO: Should delete already PUT objects???
+		window = append(window, conn.PutAsync(&entry))
+	}
+	for len(window) > 0 {
+		wait()
+	}
+	if failed != nil {
+		return status, failed
+	}
+
+	if op.WriteBack {
+		sts, err := conn.FlushAsync().Result()
+		if err == nil && sts.Code != OK {
+			err = sts
+		}
+		if err != nil {
+			klog.Errorf("Upload fail for flush : %s\n", err.Error())
 			return status, err
 		}
+	}
 
-		offset += n
-		cnt++
+	return status, nil
+}
+
+// UploadProgress is the progress of UploadFileBatch, recorded in progress object on device.
+type UploadProgress struct {
+	Chunks    int   `json:"chunks"`     // Total number of chunks
+	ChunkSize int32 `json:"chunk_size"` // Size of each chunk
+	Committed int   `json:"committed"`  // Number of chunks committed, chunks of keys[:Committed] are on device
+}
+
+// UploadFileBatch is the utility function to upload file to drive, same as UploadFile except chunks are PUT
+// in batches, each batch commits atomically. Number of chunks per batch is bounded by device
+// MaxOperationCountPerBatch limit, file fits in one batch is uploaded atomically.
+// If progressKey is not nil, UploadProgress is PUT to progressKey in the same batch of chunks, so if upload
+// is interrupted, it can be continued by ResumeUploadFile, or chunks committed deleted by CleanupUpload.
+// Progress object is deleted in the last batch.
+// Status of each chunk is the Status of its batch. If any batch fail, upload will stop and return status.
+func UploadFileBatch(conn *BlockConnection, file string, keys [][]byte, chunkSize int32, progressKey []byte) ([]Status, error) {
+	return uploadBatch(conn, file, keys, chunkSize, progressKey, 0)
+}
+
+// ResumeUploadFile continues interrupted UploadFileBatch from the progress recorded in progressKey,
+// Status of chunks uploaded by this call are returned.
+func ResumeUploadFile(conn *BlockConnection, file string, keys [][]byte, chunkSize int32, progressKey []byte) ([]Status, error) {
+	progress, err := GetUploadProgress(conn, progressKey)
+	if err != nil {
+		return nil, err
+	}
+	if progress.Chunks != len(keys) || progress.ChunkSize != chunkSize {
+		return nil, fmt.Errorf("Upload progress mismatch, expect %d chunks of %d bytes", progress.Chunks, progress.ChunkSize)
+	}
+	return uploadBatch(conn, file, keys, chunkSize, progressKey, progress.Committed)
+}
+
+// GetUploadProgress gets UploadProgress recorded in progressKey by UploadFileBatch.
+func GetUploadProgress(conn *BlockConnection, progressKey []byte) (*UploadProgress, error) {
+	entry, status, err := conn.Get(progressKey)
+	if err != nil {
+		return nil, err
+	}
+	if status.Code != OK {
+		return nil, status
+	}
+	progress := &UploadProgress{}
+	if err = json.Unmarshal(entry.Value, progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// CleanupUpload deletes chunks committed by interrupted UploadFileBatch, and the progress object.
+func CleanupUpload(conn *BlockConnection, keys [][]byte, progressKey []byte) error {
+	progress, err := GetUploadProgress(conn, progressKey)
+	if err != nil {
+		return err
+	}
+	if progress.Committed > len(keys) {
+		return fmt.Errorf("Expect %d keys, actual %d keys", progress.Chunks, len(keys))
+	}
+	for cnt := 0; cnt < progress.Committed; cnt++ {
+		sts, err := conn.Delete(&Record{Key: keys[cnt], Sync: SyncWriteBack, Force: true})
+		if err == nil && sts.Code != OK && sts.Code != RemoteNotFound {
+			err = sts
+		}
+		if err != nil {
+			klog.Errorf("Cleanup fail for chunk[%02d], key[%s] : %s\n", cnt, keys[cnt], err.Error())
+			return err
+		}
+	}
+	sts, err := conn.Delete(&Record{Key: progressKey, Sync: SyncWriteThrough, Force: true})
+	if err == nil && sts.Code != OK {
+		err = sts
+	}
+	return err
+}
+
+func uploadBatch(conn *BlockConnection, file string, keys [][]byte, chunkSize int32, progressKey []byte, start int) ([]Status, error) {
+	f, err := openUpload(file, keys, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(int64(start)*int64(chunkSize), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	max := DefaultMaxOperationCountPerBatch
+	if limits := conn.nbc.service.limits(); limits != nil && limits.MaxOperationCountPerBatch > 0 {
+		max = int(limits.MaxOperationCountPerBatch)
+	}
+	if progressKey != nil {
+		// Leave one for progress object
+		max--
+	}
+	if max <= 0 {
+		return nil, errors.New("Device MaxOperationCountPerBatch too small for batch upload")
+	}
+
+	status := make([]Status, 0, len(keys)-start)
+
+	for cnt := start; cnt < len(keys); {
+		end := cnt + max
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		b, sts, err := conn.NewBatch()
+		if err == nil && sts.Code != OK {
+			err = sts
+		}
+		if err != nil {
+			klog.Errorf("Upload fail for batch start : %s\n", err.Error())
+			return status, err
+		}
+
+		err = uploadChunks(b, f, keys[cnt:end], chunkSize, end == len(keys))
+		if err == nil && progressKey != nil {
+			err = putProgress(conn, b, progressKey, &UploadProgress{Chunks: len(keys), ChunkSize: chunkSize, Committed: end})
+		}
+		if err != nil {
+			b.Abort()
+			return status, err
+		}
+
+		_, sts, err = b.Commit()
+		for k := cnt; k < end; k++ {
+			status = append(status, sts)
+		}
+		if err == nil && sts.Code != OK {
+			err = sts
+		}
+		if err != nil {
+			klog.Errorf("Upload fail for chunk[%02d -- %02d] : %s\n", cnt, end-1, err.Error())
+			return status, err
+		}
+
+		cnt = end
 	}
 
 	return status, nil
 }
+
+// uploadChunks reads chunks from f and PUT them to keys in batch b.
+// Each chunk has its own buffer, batch keeps the records until commit.
+func uploadChunks(b *Batch, f io.Reader, keys [][]byte, chunkSize int32, last bool) error {
+	for k, key := range keys {
+		buf := make([]byte, chunkSize)
+		n, err := io.ReadFull(f, buf)
+		if err == io.ErrUnexpectedEOF && last && k == len(keys)-1 {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+
+		tag, _ := ComputeTag(AlgorithmSHA1, buf[:n])
+		entry := Record{
+			Key:   key,
+			Value: buf[:n],
+			Tag:   tag,
+			Sync:  SyncWriteThrough,
+			Algo:  AlgorithmSHA1,
+			Force: true,
+		}
+		if err = b.Put(&entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putProgress PUTs progress to progressKey in batch b, or DELETEs progressKey if all chunks committed.
+func putProgress(conn *BlockConnection, b *Batch, progressKey []byte, progress *UploadProgress) error {
+	if progress.Committed == progress.Chunks {
+		// Batch DELETE fails the batch for not exist key, only delete existing progress object
+		_, status, err := conn.GetMetadata(progressKey)
+		if err != nil {
+			return err
+		}
+		if status.Code == RemoteNotFound {
+			return nil
+		}
+		if status.Code != OK {
+			return status
+		}
+		return b.Delete(&Record{Key: progressKey, Sync: SyncWriteThrough, Force: true})
+	}
+
+	value, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	return b.Put(&Record{Key: progressKey, Value: value, Sync: SyncWriteThrough, Force: true})
+}
+
+// ChunkReader reads chunks stored by UploadFile in order, as one stream.
+// Each chunk is fetched when previous chunk fully read. If chunk GET fails or the chunk
+// doesn't match its tag, Read returns the chunk Status as error.
+type ChunkReader struct {
+	conn   *BlockConnection
+	keys   [][]byte
+	status []Status
+	chunk  []byte
+	err    error
+}
+
+// NewChunkReader returns ChunkReader to read chunks of keys.
+func NewChunkReader(conn *BlockConnection, keys [][]byte) *ChunkReader {
+	return &ChunkReader{conn: conn, keys: keys}
+}
+
+// next fetches next chunk and verifies its status and tag.
+func (r *ChunkReader) next() error {
+	cnt := len(r.status)
+	key := r.keys[cnt]
+	entry, sts, err := r.conn.Get(key)
+	if err == nil && sts.Code == OK && len(entry.Tag) > 0 {
+		if tag, terr := ComputeTag(entry.Algo, entry.Value); terr == nil && !bytes.Equal(tag, entry.Tag) {
+			sts = Status{Code: ClientIntegrityError, ErrorMsg: "Chunk value not match tag"}
+		}
+	}
+	r.status = append(r.status, sts)
+	if err != nil {
+		return err
+	}
+	if sts.Code != OK {
+		klog.Errorf("Download fail for chunk[%02d], key[%s] : %s\n", cnt, key, sts.Error())
+		return sts
+	}
+	r.chunk = entry.Value
+	return nil
+}
+
+// Read reads from current chunk, io.EOF is returned after all chunks read.
+func (r *ChunkReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if len(r.status) == len(r.keys) {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// Status returns Status of each chunk fetched so far.
+func (r *ChunkReader) Status() []Status {
+	return r.status
+}
+
+// DownloadFile is the utility function to download file uploaded by UploadFile.
+// conn is BlockConnection to drive, keys are the object keys of chunks in order, file is the full path to the file.
+// File is written to a temporary file in the same directory first, and renamed to file only if all chunks downloaded,
+// so existing file is never left partially written.
+// If any chunk GET fail, download will stop and return status.
+func DownloadFile(conn *BlockConnection, keys [][]byte, file string) ([]Status, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("No chunk keys, can't download")
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".")
+	if err != nil {
+		return nil, err
+	}
+	tmp := f.Name()
+
+	r := NewChunkReader(conn, keys)
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, file)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return r.Status(), err
+	}
+
+	return r.Status(), nil
+}
@@ -17,7 +17,15 @@
 
 package kinetic
 
-import "fmt"
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func ExampleUpdateFirmware() {
 	// Set the log leverl to debug
@@ -42,3 +50,235 @@ func ExampleUpdateFirmware() {
 		fmt.Println("Firmware update fail: ", file, err)
 	}
 }
+
+func TestUploadDownloadFile(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	dir, err := ioutil.TempDir("", "kinetic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 2500)
+	rand.Read(data)
+	src := filepath.Join(dir, "src")
+	if err = ioutil.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := [][]byte{[]byte("file-0"), []byte("file-1"), []byte("file-2")}
+	if _, err = UploadFile(conn, src, keys, 1000); err != nil {
+		t.Fatal("UploadFile Failure", err)
+	}
+
+	dst := filepath.Join(dir, "dst")
+	status, err := DownloadFile(conn, keys, dst)
+	if err != nil || len(status) != len(keys) {
+		t.Fatal("DownloadFile Failure", err, len(status))
+	}
+	if read, err := ioutil.ReadFile(dst); err != nil || !bytes.Equal(read, data) {
+		t.Fatal("Downloaded file content Failure", err)
+	}
+
+	// Missing chunk fails download and leaves existing file untouched
+	status, err = DownloadFile(conn, append(keys, []byte("file-3")), dst)
+	if err == nil || len(status) != 4 || status[3].Code != RemoteNotFound {
+		t.Fatal("DownloadFile with missing chunk expect failure", err, len(status))
+	}
+	if read, err := ioutil.ReadFile(dst); err != nil || !bytes.Equal(read, data) {
+		t.Fatal("Failed download changed existing file", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 2 {
+		t.Fatal("Failed download left temporary file", len(files))
+	}
+
+	// Corrupted chunk detected
+	conn.Put(&Record{Key: keys[1], Value: data[:1000], Tag: []byte("bad tag"), Algo: AlgorithmSHA1, Sync: SyncWriteBack, Force: true})
+	r := NewChunkReader(conn, keys)
+	if _, err = ioutil.ReadAll(r); err == nil || r.Status()[1].Code != ClientIntegrityError {
+		t.Fatal("ChunkReader with corrupted chunk expect integrity error", err)
+	}
+
+	for _, key := range keys {
+		conn.Delete(&Record{Key: key, Sync: SyncWriteBack, Force: true})
+	}
+}
+
+func TestUploadFileNonBlock(t *testing.T) {
+	nbc, err := NewNonBlockConnection(option)
+	if err != nil {
+		t.Fatal("Nonblocking connection Failure", err)
+	}
+	defer nbc.Close()
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	dir, err := ioutil.TempDir("", "kinetic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 9500)
+	rand.Read(data)
+	src := filepath.Join(dir, "src")
+	if err = ioutil.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make([][]byte, 10)
+	for k := range keys {
+		keys[k] = []byte(fmt.Sprintf("nbfile-%d", k))
+	}
+	status, err := UploadFileNonBlock(nbc, src, keys, 1000, UploadOptions{Outstanding: 3, WriteBack: true})
+	if err != nil || len(status) != len(keys) {
+		t.Fatal("UploadFileNonBlock Failure", err, len(status))
+	}
+
+	dst := filepath.Join(dir, "dst")
+	if _, err = DownloadFile(conn, keys, dst); err != nil {
+		t.Fatal("DownloadFile Failure", err)
+	}
+	if read, err := ioutil.ReadFile(dst); err != nil || !bytes.Equal(read, data) {
+		t.Fatal("Downloaded file content Failure", err)
+	}
+
+	// Chunk with key exceeding MaxKeySize fails upload
+	bad := append([][]byte{}, keys...)
+	bad[5] = bytes.Repeat([]byte("K"), nbc.service.maxKeySize()+1)
+	status, err = UploadFileNonBlock(nbc, src, bad, 1000, UploadOptions{})
+	if err == nil || len(status) < 6 {
+		t.Fatal("UploadFileNonBlock with bad key expect failure", err, len(status))
+	}
+
+	for _, key := range keys {
+		conn.Delete(&Record{Key: key, Sync: SyncWriteBack, Force: true})
+	}
+}
+
+func TestUploadFileBatch(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	dir, err := ioutil.TempDir("", "kinetic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 2950)
+	rand.Read(data)
+	src := filepath.Join(dir, "src")
+	if err = ioutil.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make([][]byte, 30)
+	for k := range keys {
+		keys[k] = []byte(fmt.Sprintf("batchfile-%02d", k))
+	}
+	bad := append([][]byte{}, keys...)
+	bad[20] = bytes.Repeat([]byte("K"), conn.nbc.service.maxKeySize()+1)
+	progressKey := []byte("batchfile-progress")
+
+	exist := func(key []byte) bool {
+		_, status, err := conn.GetMetadata(key)
+		if err != nil {
+			t.Fatal("Blocking GetMetadata Failure", err)
+		}
+		return status.Code == OK
+	}
+
+	// Second batch fails, first batch and its progress committed
+	status, err := UploadFileBatch(conn, src, bad, 100, progressKey)
+	if err == nil || len(status) != 28 || status[0].Code != OK || status[14].Code == OK {
+		t.Fatal("UploadFileBatch with bad key expect failure", err, len(status))
+	}
+	progress, err := GetUploadProgress(conn, progressKey)
+	if err != nil || progress.Committed != 14 || progress.Chunks != 30 {
+		t.Fatal("GetUploadProgress Failure", err, progress)
+	}
+	if !exist(keys[13]) || exist(keys[14]) {
+		t.Fatal("Failed batch partially committed")
+	}
+
+	status, err = ResumeUploadFile(conn, src, keys, 100, progressKey)
+	if err != nil || len(status) != 16 {
+		t.Fatal("ResumeUploadFile Failure", err, len(status))
+	}
+	if exist(progressKey) {
+		t.Fatal("Progress object not deleted after upload completed")
+	}
+	dst := filepath.Join(dir, "dst")
+	if _, err = DownloadFile(conn, keys, dst); err != nil {
+		t.Fatal("DownloadFile Failure", err)
+	}
+	if read, err := ioutil.ReadFile(dst); err != nil || !bytes.Equal(read, data) {
+		t.Fatal("Downloaded file content Failure", err)
+	}
+	for _, key := range keys {
+		conn.Delete(&Record{Key: key, Sync: SyncWriteBack, Force: true})
+	}
+
+	// Cleanup interrupted upload
+	if _, err = UploadFileBatch(conn, src, bad, 100, progressKey); err == nil {
+		t.Fatal("UploadFileBatch with bad key expect failure")
+	}
+	if err = CleanupUpload(conn, keys, progressKey); err != nil {
+		t.Fatal("CleanupUpload Failure", err)
+	}
+	if exist(keys[0]) || exist(keys[13]) || exist(progressKey) {
+		t.Fatal("CleanupUpload left chunks")
+	}
+
+	// Small file uploaded atomically
+	_ = ioutil.WriteFile(src, data[:1000], 0644)
+	if _, err = UploadFileBatch(conn, src, bad[15:25], 100, nil); err == nil {
+		t.Fatal("UploadFileBatch with bad key expect failure")
+	}
+	if exist(keys[15]) {
+		t.Fatal("Failed small file upload partially committed")
+	}
+}
+
+func TestUploadChunks(t *testing.T) {
+	conn, err := NewBlockConnection(option)
+	if err != nil {
+		t.Fatal("Blocking connection Failure", err)
+	}
+	defer conn.Close()
+
+	b, status, err := conn.NewBatch()
+	if err != nil || status.Code != OK {
+		t.Fatal("Blocking NewBatch Failure", err, status.String())
+	}
+	defer b.Abort()
+
+	data := make([]byte, 250)
+	rand.Read(data)
+	keys := [][]byte{[]byte("chunks-0"), []byte("chunks-1"), []byte("chunks-2")}
+	if err = uploadChunks(b, bytes.NewReader(data), keys, 100, true); err != nil {
+		t.Fatal("uploadChunks Failure", err)
+	}
+	// Records kept by batch must not share the read buffer
+	for k, r := range b.records {
+		end := (k + 1) * 100
+		if end > len(data) {
+			end = len(data)
+		}
+		if !bytes.Equal(r.Value, data[k*100:end]) {
+			t.Fatal("Batch record value overwritten by later chunk", k)
+		}
+	}
+}